@@ -0,0 +1,78 @@
+package tenant
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	baggageHeader      = "baggage"
+	tenantIdBaggageKey = "dv.tenant.id"
+)
+
+// WithResponseBaggage adds the tenant id as member dv.tenant.id of the W3C baggage header of the response,
+// e.g. baggage: dv.tenant.id=a12be5. Members which have already been set by the next handler are preserved.
+func WithResponseBaggage() Option {
+	return func(v *Verifier) error {
+		v.responseBaggage = true
+		return nil
+	}
+}
+
+// baggageWriter adds the baggage member to the response headers before they are written.
+type baggageWriter struct {
+	http.ResponseWriter
+	member      string
+	wroteHeader bool
+}
+
+func newBaggageWriter(rw http.ResponseWriter, tenantId string) *baggageWriter {
+	return &baggageWriter{ResponseWriter: rw, member: tenantIdBaggageKey + "=" + url.PathEscape(tenantId)}
+}
+
+func (bw *baggageWriter) WriteHeader(code int) {
+	bw.addBaggage()
+	bw.ResponseWriter.WriteHeader(code)
+}
+
+func (bw *baggageWriter) Write(b []byte) (int, error) {
+	bw.addBaggage()
+	return bw.ResponseWriter.Write(b)
+}
+
+// addBaggage appends the member to the baggage header unless the headers have already been written.
+func (bw *baggageWriter) addBaggage() {
+	if bw.wroteHeader {
+		return
+	}
+	bw.wroteHeader = true
+	header := bw.Header()
+	members := append(header[http.CanonicalHeaderKey(baggageHeader)], bw.member)
+	header.Set(baggageHeader, strings.Join(members, ","))
+}
+
+// Unwrap returns the wrapped ResponseWriter so that http.ResponseController can access its features.
+func (bw *baggageWriter) Unwrap() http.ResponseWriter {
+	return bw.ResponseWriter
+}
+
+// Flush adds the baggage and flushes the wrapped ResponseWriter if it is a http.Flusher, e.g. for server-sent events.
+func (bw *baggageWriter) Flush() {
+	bw.addBaggage()
+	flush(bw.ResponseWriter)
+}
+
+// Hijack hijacks the connection of the wrapped ResponseWriter if it is a http.Hijacker, e.g. for websockets.
+func (bw *baggageWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return hijack(bw.ResponseWriter)
+}
+
+// ReadFrom adds the baggage and uses the io.ReaderFrom of the wrapped ResponseWriter if there is one.
+func (bw *baggageWriter) ReadFrom(r io.Reader) (int64, error) {
+	bw.addBaggage()
+	return readFrom(bw.ResponseWriter, r)
+}
@@ -0,0 +1,76 @@
+package tenant_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestResponseBaggage_AddsTenantIdToResponseBaggage(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	rec := httptest.NewRecorder()
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithResponseBaggage())(&handlerSpy).ServeHTTP(rec, req)
+
+	if got, want := rec.Header().Get("baggage"), "dv.tenant.id=a12be5"; got != want {
+		t.Errorf("got wrong baggage header: got %v want %v", got, want)
+	}
+}
+
+func TestResponseBaggageAndExistingBaggage_PreservesExistingMembers(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("baggage", "userId=alice,isProduction=false")
+		rw.WriteHeader(http.StatusCreated)
+	})
+	rec := httptest.NewRecorder()
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithResponseBaggage())(next).ServeHTTP(rec, req)
+
+	if got, want := rec.Header().Get("baggage"), "userId=alice,isProduction=false,dv.tenant.id=a12be5"; got != want {
+		t.Errorf("got wrong baggage header: got %v want %v", got, want)
+	}
+	if rec.Code != http.StatusCreated {
+		t.Errorf("got wrong status code: got %v want %v", rec.Code, http.StatusCreated)
+	}
+}
+
+func TestResponseBaggageAndRejectedRequest_DoesntAddBaggage(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(tenantIdHeader, "other")
+	rec := httptest.NewRecorder()
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithResponseBaggage())(&handlerSpy{}).ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("baggage"); got != "" {
+		t.Errorf("expected no baggage header but got %v", got)
+	}
+}
+
+func TestResponseBaggageAndFlushingHandler_AddsBaggageAndFlushes(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	var isFlusher, isHijacker bool
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, isHijacker = rw.(http.Hijacker)
+		var flusher http.Flusher
+		if flusher, isFlusher = rw.(http.Flusher); isFlusher {
+			flusher.Flush()
+		}
+	})
+	rec := httptest.NewRecorder()
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithResponseBaggage())(next).ServeHTTP(rec, req)
+
+	if !isFlusher || !rec.Flushed {
+		t.Error("response should have been flushed")
+	}
+	if !isHijacker {
+		t.Error("response writer should be a http.Hijacker")
+	}
+	if got, want := rec.Header().Get("baggage"), "dv.tenant.id=a12be5"; got != want {
+		t.Errorf("got wrong baggage header: got %v want %v", got, want)
+	}
+}
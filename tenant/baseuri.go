@@ -0,0 +1,82 @@
+package tenant
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// CanonicalBaseUri returns a canonical form of the given base uri which is suitable for comparisons.
+// The scheme and host are lowercased and the port is removed if it is the default port of the scheme.
+// So "HTTPS://X.example.com:443" and "https://x.example.com" have the same canonical form.
+// The base uri stored in the context is not affected by this function.
+//
+// If u can't be parsed as an absolute uri it is returned unchanged.
+func CanonicalBaseUri(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return u
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	host := strings.ToLower(parsed.Hostname())
+	port := parsed.Port()
+	if port != "" && port != defaultPorts[parsed.Scheme] {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		// IPv6 literals must be enclosed in brackets if there is no port
+		host = "[" + host + "]"
+	}
+	parsed.Host = host
+	return parsed.String()
+}
+
+// canonicalHost returns the host of the canonical form of the given uri or an empty string if it is not an absolute uri.
+func canonicalHost(u string) string {
+	parsed, err := url.Parse(CanonicalBaseUri(u))
+	if err != nil || parsed.Scheme == "" {
+		return ""
+	}
+	return parsed.Host
+}
+
+// LogSafeBaseUri returns the systemBaseUri from the context reduced to scheme://host[:port] so that it can be
+// logged without leaking userinfo, path, query or fragment. An empty string is returned if there is no
+// systemBaseUri on the context or if it can't be parsed as an absolute uri.
+func LogSafeBaseUri(ctx context.Context) string {
+	systemBaseUri, err := SystemBaseUriFromCtx(ctx)
+	if err != nil {
+		return ""
+	}
+	parsed, err := url.Parse(systemBaseUri)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return ""
+	}
+	return (&url.URL{Scheme: parsed.Scheme, Host: parsed.Host}).String()
+}
+
+// cacheKeyEscaper escapes the separator of the parts of a cache key so that different parts never produce the same key.
+var cacheKeyEscaper = strings.NewReplacer("%", "%25", "|", "%7C")
+
+// CacheKeyFromCtx returns a key for caching data of the tenant from the context in the form
+// "<tenantId>|<baseUriHost>|<suffix>", e.g. "a12be5|sample.example.com|documents". The host is taken from
+// the canonical form of the systemBaseUri (cf. CanonicalBaseUri) and occurrences of "|" in the parts are escaped.
+// So the key is the same for all requests of a tenant and differs for different tenants.
+//
+// An error is returned if ctx doesn't contain a tenantId or systemBaseUri.
+func CacheKeyFromCtx(ctx context.Context, suffix string) (string, error) {
+	tenantId, err := IdFromCtx(ctx)
+	if err != nil {
+		return "", err
+	}
+	systemBaseUri, err := SystemBaseUriFromCtx(ctx)
+	if err != nil {
+		return "", err
+	}
+	return cacheKeyEscaper.Replace(tenantId) + "|" + cacheKeyEscaper.Replace(canonicalHost(systemBaseUri)) + "|" + cacheKeyEscaper.Replace(suffix), nil
+}
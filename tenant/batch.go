@@ -0,0 +1,88 @@
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const batchSignatureHeader = "x-dv-batch-sig"
+
+// batchMessageLabel is prepended to the signed message of a batch so that a batch signature can't be used as
+// the signature of the tenant headers or of a cookie and vice versa.
+const batchMessageLabel = "dv-tenant-batch\x00"
+
+// batchItem is a single tenant operation of a batch request.
+type batchItem struct {
+	TenantId      string `json:"tenantId"`
+	SystemBaseUri string `json:"systemBaseUri"`
+}
+
+// ValidateBatch validates a request which contains operations for several tenants which are signed together.
+// The body must be a JSON array of objects with the tenant of each operation, e.g.
+//
+//	[{"tenantId":"a12be5","systemBaseUri":"https://a.example.com"},{"tenantId":"b34cd6","systemBaseUri":"https://b.example.com"}]
+//
+// Other properties of the objects are ignored. The header x-dv-batch-sig must contain the base64 encoded HMAC-SHA256
+// of the canonical form of the batch computed with key. The canonical form consists of the label "dv-tenant-batch"
+// followed by a NUL byte and the systemBaseUri and tenantId of all items in the order of the array separated by
+// a newline, e.g. "dv-tenant-batch\x00https://a.example.com\na12be5\nhttps://b.example.com\nb34cd6".
+//
+// The tenant information of the items is returned in the order of the array. The body is restored so that it
+// can be read again. Of the options only WithMaxBodySize is applied; bodies larger than the max body size, which
+// defaults to 10 MiB, are rejected with status code 413. An error is returned if the batch is malformed or if the
+// signature is not valid for the batch as a whole. Like the errors of ValidateRequest it wraps the sentinel errors,
+// e.g. ErrInvalidSignature.
+func ValidateBatch(r *http.Request, key []byte, options ...Option) ([]TenantInfo, error) {
+	v, err := newVerifier("", nil, nil, options)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == 0 {
+		return nil, &verificationError{http.StatusInternalServerError, "validating batch signature because secret signature key has not been configured", ErrMissingSignatureKey}
+	}
+	base64Signature := headerValue(r.Header, batchSignatureHeader)
+	if base64Signature == "" {
+		return nil, &verificationError{http.StatusForbidden, fmt.Sprintf("batch signature header '%v' is missing", batchSignatureHeader), ErrMissingSignature}
+	}
+	signature, err := decodeSignature(base64Signature)
+	if err != nil {
+		return nil, &verificationError{http.StatusForbidden, fmt.Sprintf("decoding batch signature '%v' as base 64 data because: %v", base64Signature, err), ErrMalformedSignature}
+	}
+
+	// the body is read only after the key and the signature header have been checked
+	body, vErr := readAndRestoreBody(r, v.maxBodySize)
+	if vErr != nil {
+		return nil, vErr
+	}
+	var items []batchItem
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, &verificationError{http.StatusBadRequest, fmt.Sprintf("decoding batch as JSON array because: %v", err), nil}
+	}
+	if len(items) == 0 {
+		return nil, &verificationError{http.StatusBadRequest, "batch doesn't contain any items", nil}
+	}
+	values := make([]string, 0, 2*len(items))
+	for i, item := range items {
+		if item.TenantId == "" || item.SystemBaseUri == "" {
+			return nil, &verificationError{http.StatusBadRequest, fmt.Sprintf("batch item %v must contain tenantId and systemBaseUri", i), nil}
+		}
+		if strings.Contains(item.TenantId, "\n") || strings.Contains(item.SystemBaseUri, "\n") {
+			return nil, &verificationError{http.StatusBadRequest, fmt.Sprintf("batch item %v contains a newline", i), nil}
+		}
+		values = append(values, item.SystemBaseUri, item.TenantId)
+	}
+	if !signatureIsValidForAnyKey([]byte(batchMessageLabel+strings.Join(values, "\n")), signature, [][]byte{key}) {
+		return nil, &verificationError{http.StatusForbidden, fmt.Sprintf("batch signature '%v' is not valid", base64Signature), ErrInvalidSignature}
+	}
+	infos := make([]TenantInfo, 0, len(items))
+	for _, item := range items {
+		infos = append(infos, TenantInfo{
+			TenantId:          item.TenantId,
+			SystemBaseUri:     normalizeBaseUri(item.SystemBaseUri),
+			SignatureVerified: true,
+		})
+	}
+	return infos, nil
+}
@@ -0,0 +1,113 @@
+package tenant_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+const batchSignatureHeader = "x-dv-batch-sig"
+
+const batchMessageLabel = "dv-tenant-batch\x00"
+
+const batchBody = `[{"tenantId":"a12be5","systemBaseUri":"https://a.example.com/","operation":"delete"},{"tenantId":"b34cd6","systemBaseUri":"https://b.example.com","operation":"create"}]`
+
+func TestValidBatch_ValidateBatch_ReturnsTenantInfos(t *testing.T) {
+	req := batchRequest(t, batchBody, base64Signature(batchMessageLabel+"https://a.example.com/\na12be5\nhttps://b.example.com\nb34cd6", signatureKey))
+
+	infos, err := tenant.ValidateBatch(req, signatureKey)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []tenant.TenantInfo{
+		{TenantId: "a12be5", SystemBaseUri: "https://a.example.com", SignatureVerified: true},
+		{TenantId: "b34cd6", SystemBaseUri: "https://b.example.com", SignatureVerified: true},
+	}
+	if !reflect.DeepEqual(infos, want) {
+		t.Errorf("got wrong tenant infos: got %+v want %+v", infos, want)
+	}
+	if body, _ := ioutil.ReadAll(req.Body); string(body) != batchBody {
+		t.Errorf("body has not been restored: got %v want %v", body, batchBody)
+	}
+}
+
+func TestTamperedItem_ValidateBatch_ReturnsInvalidSignatureError(t *testing.T) {
+	tampered := strings.Replace(batchBody, "b34cd6", "evil", 1)
+	req := batchRequest(t, tampered, base64Signature(batchMessageLabel+"https://a.example.com/\na12be5\nhttps://b.example.com\nb34cd6", signatureKey))
+
+	infos, err := tenant.ValidateBatch(req, signatureKey)
+
+	if !errors.Is(err, tenant.ErrInvalidSignature) {
+		t.Errorf("got wrong error: got %v want %v", err, tenant.ErrInvalidSignature)
+	}
+	if infos != nil {
+		t.Errorf("expected no tenant infos but got %+v", infos)
+	}
+}
+
+func TestMalformedBatch_ValidateBatch_ReturnsError(t *testing.T) {
+	for name, body := range map[string]string{
+		"no JSON":        "tenantId=a12be5",
+		"empty":          "[]",
+		"missing tenant": `[{"systemBaseUri":"https://a.example.com"}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := batchRequest(t, body, base64Signature(batchMessageLabel, signatureKey))
+
+			if _, err := tenant.ValidateBatch(req, signatureKey); err == nil {
+				t.Error("expected an error for a malformed batch")
+			}
+		})
+	}
+}
+
+func TestUnlabeledSignature_ValidateBatch_ReturnsInvalidSignatureError(t *testing.T) {
+	req := batchRequest(t, batchBody, base64Signature("https://a.example.com/\na12be5\nhttps://b.example.com\nb34cd6", signatureKey))
+
+	_, err := tenant.ValidateBatch(req, signatureKey)
+
+	if !errors.Is(err, tenant.ErrInvalidSignature) {
+		t.Errorf("got wrong error: got %v want %v", err, tenant.ErrInvalidSignature)
+	}
+}
+
+func TestBodyLargerThanMaxBodySize_ValidateBatch_ReturnsError(t *testing.T) {
+	req := batchRequest(t, batchBody, base64Signature(batchMessageLabel+"https://a.example.com/\na12be5\nhttps://b.example.com\nb34cd6", signatureKey))
+
+	if _, err := tenant.ValidateBatch(req, signatureKey, tenant.WithMaxBodySize(int64(len(batchBody)-1))); err == nil {
+		t.Error("expected an error for a body larger than the max body size")
+	}
+}
+
+func TestMissingSignature_ValidateBatch_DoesntReadBody(t *testing.T) {
+	req := batchRequest(t, batchBody, "")
+
+	_, err := tenant.ValidateBatch(req, signatureKey)
+
+	if !errors.Is(err, tenant.ErrMissingSignature) {
+		t.Errorf("got wrong error: got %v want %v", err, tenant.ErrMissingSignature)
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != batchBody {
+		t.Errorf("body should not have been read: got %v", string(body))
+	}
+}
+
+func batchRequest(t *testing.T, body string, signature string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest("POST", "/bulk", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(batchSignatureHeader, signature)
+	return req
+}
@@ -0,0 +1,267 @@
+package tenant
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	bodySignatureHeader = "x-dv-body-sig"
+	formMediaType       = "application/x-www-form-urlencoded"
+)
+
+// bodyMessageLabel separates the signed message of a body from the messages of other signatures which are
+// created with the same key, e.g. the signature of the tenant headers.
+const bodyMessageLabel = "dv-tenant-body\x00"
+
+// SignedBodyMessage returns the message which must be signed for the body signature of a request with the given
+// systemBaseUri and tenantId (cf. WithBodySignature). So a body signature is only valid for the tenant it has been
+// created for.
+//
+// The message is the label "dv-tenant-body" followed by a zero byte, the length prefixed systemBaseUri and tenantId,
+// e.g. "26:https://sample.example.com6:a12be5", and the body. Like for SignedMessage the systemBaseUri and tenantId
+// must be taken as they are signed without any normalization, that is the values of the headers x-dv-baseuri and
+// x-dv-tenant-id, of the claims of a JWT or of a cookie. They are empty if the request doesn't contain them.
+func SignedBodyMessage(systemBaseUri, tenantId string, body []byte) []byte {
+	return append([]byte(bodyMessagePrefix(systemBaseUri, tenantId)), body...)
+}
+
+// bodyMessagePrefix returns the part of the signed message of a body which precedes the body.
+func bodyMessagePrefix(systemBaseUri, tenantId string) string {
+	return bodyMessageLabel + strconv.Itoa(len(systemBaseUri)) + ":" + systemBaseUri + strconv.Itoa(len(tenantId)) + ":" + tenantId
+}
+
+// WithBodySignature additionally requires a signature of the request body.
+//
+// The header x-dv-body-sig must contain the base64 encoded signature of the message returned by
+// SignedBodyMessage for the raw request body created with the same signature scheme as the header
+// signature, that is by default the HMAC-SHA256 computed with the signature secret key. Requests
+// without a valid body signature are rejected with status code 403.
+//
+// The body is read into memory only after all checks which depend solely on the headers, including
+// the validation of the header signature, have been passed. Afterwards the body is restored so that
+// the next handler can read it as usual.
+func WithBodySignature() Option {
+	return func(v *Verifier) error {
+		v.bodySignature = true
+		return nil
+	}
+}
+
+func (v *Verifier) verifyBodySignature(req *http.Request, claims sourceClaims) *verificationError {
+	scheme, vErr := v.requestSignatureScheme(req, claims.tenantId)
+	if vErr != nil {
+		return vErr
+	}
+	base64Signature := headerValue(req.Header, bodySignatureHeader)
+	if base64Signature == "" {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("body signature header '%v' is missing", bodySignatureHeader), ErrMissingSignature}
+	}
+	signature, err := decodeSignature(base64Signature)
+	if err != nil {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("decoding body signature '%v' as base 64 data because: %v", base64Signature, err), ErrMalformedSignature}
+	}
+	body, vErr := readAndRestoreBody(req, v.maxBodySize)
+	if vErr != nil {
+		return vErr
+	}
+	if !scheme.Verify(SignedBodyMessage(claims.systemBaseUri, claims.tenantId, body), signature) {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("body signature '%v' is not valid", base64Signature), ErrInvalidSignature}
+	}
+	return nil
+}
+
+// defaultMaxBodySize is the maximum size of a request body which is read into memory
+// if WithMaxBodySize is not used.
+const defaultMaxBodySize = 10 << 20
+
+// WithMaxBodySize limits the size of request bodies which are read into memory to verify their signature,
+// e.g. by WithBodySignature. Requests with larger bodies are rejected with status code 413 before the signature
+// is verified. Defaults to 10 MiB.
+func WithMaxBodySize(n int64) Option {
+	return func(v *Verifier) error {
+		if n <= 0 {
+			return fmt.Errorf("max body size must be positive but is %v", n)
+		}
+		v.maxBodySize = n
+		return nil
+	}
+}
+
+// readAndRestoreBody reads at most maxSize bytes of the body into memory and restores it so that the next handler
+// can read it as usual. Bodies which are larger than maxSize are rejected.
+func readAndRestoreBody(req *http.Request, maxSize int64) ([]byte, *verificationError) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, req.Body, maxSize))
+	_ = req.Body.Close()
+	if err != nil {
+		if int64(len(body)) >= maxSize {
+			return nil, &verificationError{http.StatusRequestEntityTooLarge, fmt.Sprintf("reading request body because it is larger than %v bytes", maxSize), nil}
+		}
+		return nil, &verificationError{http.StatusBadRequest, fmt.Sprintf("reading request body because: %v", err), nil}
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// WithFormBodySignature additionally requires a signature of a form encoded request body
+// (application/x-www-form-urlencoded) as it is sent by legacy webhooks.
+//
+// The header x-dv-body-sig must contain the base64 encoded signature of the message returned by SignedBodyMessage
+// for the canonical form of the parameters created with the same signature scheme as the header signature.
+// The canonical form is the url encoded form with the parameters sorted by key, e.g. "a=1&b=2&b=3"
+// (cf. url.Values.Encode). Parameters with the same key
+// keep their order. Requests without a valid body signature are rejected with status code 403 and requests
+// whose body is not form encoded with status code 400.
+//
+// The body is restored so that the next handler can read it as usual.
+// WithBodySignature takes precedence if both options are used.
+func WithFormBodySignature() Option {
+	return func(v *Verifier) error {
+		v.formBodySignature = true
+		return nil
+	}
+}
+
+func (v *Verifier) verifyFormBodySignature(req *http.Request, claims sourceClaims) *verificationError {
+	scheme, vErr := v.requestSignatureScheme(req, claims.tenantId)
+	if vErr != nil {
+		return vErr
+	}
+	base64Signature := headerValue(req.Header, bodySignatureHeader)
+	if base64Signature == "" {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("body signature header '%v' is missing", bodySignatureHeader), ErrMissingSignature}
+	}
+	signature, err := decodeSignature(base64Signature)
+	if err != nil {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("decoding body signature '%v' as base 64 data because: %v", base64Signature, err), ErrMalformedSignature}
+	}
+	if mediaType, _, err := mime.ParseMediaType(headerValue(req.Header, "content-type")); err != nil || mediaType != formMediaType {
+		return &verificationError{http.StatusBadRequest, fmt.Sprintf("body is not form encoded: content type must be '%v'", formMediaType), nil}
+	}
+	body, vErr := readAndRestoreBody(req, v.maxBodySize)
+	if vErr != nil {
+		return vErr
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return &verificationError{http.StatusBadRequest, fmt.Sprintf("parsing form encoded body because: %v", err), nil}
+	}
+	if !scheme.Verify(SignedBodyMessage(claims.systemBaseUri, claims.tenantId, []byte(form.Encode())), signature) {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("body signature '%v' is not valid for the form parameters", base64Signature), ErrInvalidSignature}
+	}
+	return nil
+}
+
+// ErrBodySignatureMismatch is returned by the request body if WithStreamingBodySignature is used
+// and the body signature in the trailer doesn't match the body.
+var ErrBodySignatureMismatch = errors.New("body signature in trailer is not valid")
+
+// WithStreamingBodySignature additionally requires a signature of the request body which is
+// transmitted in the trailer x-dv-body-sig after the body. This allows to verify large streamed
+// bodies without buffering them.
+//
+// The trailer must contain the base64 encoded HMAC-SHA256 of the message returned by SignedBodyMessage
+// for the raw request body computed with the signature secret key. Other signature schemes are not supported.
+// The request must announce the trailer (cf. http.Request.Trailer). Requests which don't announce the trailer
+// are rejected with status code 403.
+//
+// Because the signature can only be verified after the whole body has been read the next handler
+// is invoked before the body is verified. Reading the body returns ErrBodySignatureMismatch instead
+// of io.EOF if the signature is not valid. So handlers MUST NOT act upon the body before they have
+// read it completely.
+//
+// WithBodySignature takes precedence if both options are used.
+func WithStreamingBodySignature() Option {
+	return func(v *Verifier) error {
+		v.streamingBodySignature = true
+		return nil
+	}
+}
+
+func (v *Verifier) verifyBodySignatureWhileReading(req *http.Request, claims sourceClaims) *verificationError {
+	scheme, vErr := v.requestSignatureScheme(req, claims.tenantId)
+	if vErr != nil {
+		return vErr
+	}
+	hs, ok := scheme.(hmacScheme)
+	if !ok {
+		return &verificationError{http.StatusInternalServerError, fmt.Sprintf("validating body signature trailer '%v' because streaming body signatures require HMAC signatures", bodySignatureHeader), nil}
+	}
+	if _, announced := req.Trailer[http.CanonicalHeaderKey(bodySignatureHeader)]; !announced {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("body signature trailer '%v' has not been announced", bodySignatureHeader), nil}
+	}
+	macs := make([]hash.Hash, 0, len(hs.keys))
+	writers := make([]io.Writer, 0, len(hs.keys))
+	for _, key := range hs.keys {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(bodyMessagePrefix(claims.systemBaseUri, claims.tenantId)))
+		macs = append(macs, mac)
+		writers = append(writers, mac)
+	}
+	body := req.Body
+	if body == nil {
+		body = http.NoBody
+	}
+	req.Body = &signedBodyReader{
+		reader: io.TeeReader(body, io.MultiWriter(writers...)),
+		closer: body,
+		macs:   macs,
+		req:    req,
+	}
+	return nil
+}
+
+// signedBodyReader computes the signature of the body while it is read and compares it with
+// the signature in the trailer as soon as the body has been read completely.
+type signedBodyReader struct {
+	reader io.Reader
+	closer io.Closer
+	macs   []hash.Hash
+	req    *http.Request
+	err    error
+}
+
+func (r *signedBodyReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.reader.Read(p)
+	if err == io.EOF {
+		// trailers are available as soon as the body has been read completely
+		if !r.signatureIsValid(headerValue(r.req.Trailer, bodySignatureHeader)) {
+			r.err = ErrBodySignatureMismatch
+			return n, r.err
+		}
+	}
+	return n, err
+}
+
+func (r *signedBodyReader) signatureIsValid(base64Signature string) bool {
+	signature, err := decodeSignature(base64Signature)
+	if err != nil {
+		return false
+	}
+	for _, mac := range r.macs {
+		if hmac.Equal(signature, mac.Sum(nil)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *signedBodyReader) Close() error {
+	return r.closer.Close()
+}
@@ -0,0 +1,286 @@
+package tenant_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+const bodySignatureHeader = "x-dv-body-sig"
+
+func TestValidBodySignature_PassesBodyToHandler(t *testing.T) {
+	const body = `{"name":"value"}`
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Method = http.MethodPost
+	req.Body = ioutil.NopCloser(strings.NewReader(body))
+	req.Header.Set(bodySignatureHeader, bodySignature(body))
+	var bodyInHandler []byte
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		bodyInHandler, _ = ioutil.ReadAll(r.Body)
+	})
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithBodySignature())(handler).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if string(bodyInHandler) != body {
+		t.Errorf("handler got wrong body: got %v want %v", string(bodyInHandler), body)
+	}
+}
+
+func TestBodySignatureForOtherTenant_Returns403(t *testing.T) {
+	const body = `{"name":"value"}`
+	testCases := map[string]string{
+		"raw body":     base64Signature(body, signatureKey),
+		"other tenant": base64Signature(string(tenant.SignedBodyMessage("https://sample.example.com", "other", []byte(body))), signatureKey),
+		"other system": base64Signature(string(tenant.SignedBodyMessage("https://other.example.com", "a12be5", []byte(body))), signatureKey),
+	}
+	for name, signature := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := signedRequest(t, "https://sample.example.com", "a12be5")
+			req.Method = http.MethodPost
+			req.Body = ioutil.NopCloser(strings.NewReader(body))
+			req.Header.Set(bodySignatureHeader, signature)
+			handlerSpy := handlerSpy{}
+			responseSpy := responseSpy{httptest.NewRecorder()}
+
+			tenant.AddToCtx("", signatureKey, nil, tenant.WithBodySignature())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+			if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+				t.Error(err)
+			}
+			if handlerSpy.hasBeenCalled {
+				t.Error("inner handler should not have been called")
+			}
+		})
+	}
+}
+
+func TestTamperedBody_Returns403(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Method = http.MethodPost
+	req.Body = ioutil.NopCloser(strings.NewReader(`{"name":"tampered"}`))
+	req.Header.Set(bodySignatureHeader, bodySignature(`{"name":"value"}`))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithBodySignature())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+	if err := logSpy.assertLogContains("body signature"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBodyLargerThanMaxBodySize_Returns413(t *testing.T) {
+	body := strings.Repeat("a", 65)
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Method = http.MethodPost
+	req.Body = ioutil.NopCloser(strings.NewReader(body))
+	req.Header.Set(bodySignatureHeader, bodySignature(body))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithBodySignature(), tenant.WithMaxBodySize(64))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusRequestEntityTooLarge); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+}
+
+func TestBodyOfMaxBodySize_PassesBodyToHandler(t *testing.T) {
+	body := strings.Repeat("a", 64)
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Method = http.MethodPost
+	req.Body = ioutil.NopCloser(strings.NewReader(body))
+	req.Header.Set(bodySignatureHeader, bodySignature(body))
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithBodySignature(), tenant.WithMaxBodySize(64))(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBadBaseUriAndBodySignature_Returns403WithoutReadingBody(t *testing.T) {
+	const body = `{"name":"value"}`
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(systemBaseUriHeader, "https://evil.example.com")
+	req.Method = http.MethodPost
+	bodySpy := &bodyReaderSpy{Reader: strings.NewReader(body)}
+	req.Body = bodySpy
+	req.Header.Set(bodySignatureHeader, bodySignature(body))
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithBodySignature())(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if bodySpy.hasBeenRead {
+		t.Error("body should not have been read")
+	}
+}
+
+func TestValidFormBodySignature_PassesBodyToHandler(t *testing.T) {
+	// the parameters are signed in sorted order
+	const body = "event=created&id=4711&id=4712&actor=jane+doe"
+	req := formRequest(t, body, bodySignature("actor=jane+doe&event=created&id=4711&id=4712"))
+	var idsInHandler []string
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		idsInHandler = r.PostForm["id"]
+	})
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithFormBodySignature())(handler).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if strings.Join(idsInHandler, ",") != "4711,4712" {
+		t.Errorf("handler got wrong form parameters: got %v want %v", idsInHandler, []string{"4711", "4712"})
+	}
+}
+
+func TestTamperedFormParameter_Returns403(t *testing.T) {
+	req := formRequest(t, "event=deleted&id=4711", bodySignature("event=created&id=4711"))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithFormBodySignature())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+}
+
+func TestFormBodySignatureAndJSONBody_Returns400(t *testing.T) {
+	const body = `{"event":"created"}`
+	req := formRequest(t, body, bodySignature(body))
+	req.Header.Set("Content-Type", "application/json")
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithFormBodySignature())(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+}
+
+func formRequest(t *testing.T, body, bodySignature string) *http.Request {
+	t.Helper()
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Method = http.MethodPost
+	req.Body = ioutil.NopCloser(strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(bodySignatureHeader, bodySignature)
+	return req
+}
+
+// bodySignature returns the body signature of a request signed by signedRequest.
+func bodySignature(body string) string {
+	return base64Signature(string(tenant.SignedBodyMessage("https://sample.example.com", "a12be5", []byte(body))), signatureKey)
+}
+
+type bodyReaderSpy struct {
+	io.Reader
+	hasBeenRead bool
+}
+
+func (spy *bodyReaderSpy) Read(p []byte) (int, error) {
+	spy.hasBeenRead = true
+	return spy.Reader.Read(p)
+}
+
+func (spy *bodyReaderSpy) Close() error {
+	return nil
+}
+
+func TestStreamingBodyWithValidTrailerSignature_ReadsBody(t *testing.T) {
+	const body = "a large streamed body"
+	req := streamingRequest(t, body, bodySignature(body))
+	var bodyInHandler []byte
+	var readErr error
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		bodyInHandler, readErr = ioutil.ReadAll(r.Body)
+	})
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithStreamingBodySignature())(handler).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if readErr != nil {
+		t.Errorf("unexpected error reading body: %v", readErr)
+	}
+	if string(bodyInHandler) != body {
+		t.Errorf("handler got wrong body: got %v want %v", string(bodyInHandler), body)
+	}
+}
+
+func TestStreamingBodyWithMismatchingTrailerSignature_ReadReturnsError(t *testing.T) {
+	req := streamingRequest(t, "a tampered streamed body", bodySignature("a large streamed body"))
+	var readErr error
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, readErr = ioutil.ReadAll(r.Body)
+	})
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithStreamingBodySignature())(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	if readErr != tenant.ErrBodySignatureMismatch {
+		t.Errorf("got wrong error reading body: got %v want %v", readErr, tenant.ErrBodySignatureMismatch)
+	}
+}
+
+func TestStreamingBodyWithoutAnnouncedTrailer_Returns403(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Body = ioutil.NopCloser(strings.NewReader("a large streamed body"))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithStreamingBodySignature())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+}
+
+func streamingRequest(t *testing.T, body, trailerSignature string) *http.Request {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Method = http.MethodPost
+	req.Body = ioutil.NopCloser(strings.NewReader(body))
+	req.Trailer = http.Header{http.CanonicalHeaderKey(bodySignatureHeader): []string{trailerSignature}}
+	return req
+}
@@ -0,0 +1,111 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Config is a declarative alternative to the functional options which can be unmarshaled from
+// configuration files, e.g. JSON or YAML. The zero value of a field selects the default of the
+// corresponding option.
+type Config struct {
+	// DefaultSystemBaseUri is used if the request doesn't contain the header x-dv-baseuri (cf. WithDefaultSystemBaseUri).
+	DefaultSystemBaseUri string `json:"defaultSystemBaseUri"`
+	// SignatureSecretKeys are the keys for HMAC signatures (cf. WithSignatureSecretKeys).
+	// In JSON the keys are base64 encoded strings.
+	SignatureSecretKeys [][]byte `json:"signatureSecretKeys"`
+	// InboundKeys are the keys which verify HMAC signatures of incoming requests like SignatureSecretKeys.
+	// Use either InboundKeys or SignatureSecretKeys. In JSON the keys are base64 encoded strings.
+	InboundKeys [][]byte `json:"inboundKeys"`
+	// OutboundKey is the key which signs outgoing requests (cf. WithOutboundKey). It is never used to verify
+	// incoming requests. In JSON the key is a base64 encoded string.
+	OutboundKey []byte `json:"outboundKey"`
+	// Scheme verifies the signatures instead of SignatureSecretKeys (cf. AddToCtxWithVerifier).
+	Scheme SignatureScheme `json:"-"`
+	// Logger logs why requests have been rejected (cf. WithLogger).
+	Logger func(ctx context.Context, message string) `json:"-"`
+	// SignatureVersions are the accepted signature versions (cf. WithSignatureVersions).
+	SignatureVersions []int `json:"signatureVersions"`
+	// InitiatorHeaders are the headers which are used to determine the initiator (cf. WithInitiatorHeaderPriority).
+	InitiatorHeaders []string `json:"initiatorHeaders"`
+	// MaxBaseUriLength is the maximum length of the identity headers (cf. WithMaxBaseUriLength).
+	MaxBaseUriLength int `json:"maxBaseUriLength"`
+	// RequireBothIdentityHeaders rejects requests with only one identity header (cf. WithRequireBothIdentityHeaders).
+	RequireBothIdentityHeaders bool `json:"requireBothIdentityHeaders"`
+	// AllowedHosts are the allowed hosts of the systemBaseUri (cf. WithAllowedHosts).
+	AllowedHosts []string `json:"allowedHosts"`
+}
+
+// NewFromConfig creates a Verifier which is configured by cfg. A descriptive error is returned if cfg is invalid.
+func NewFromConfig(cfg Config) (*Verifier, error) {
+	options, err := cfg.options()
+	if err != nil {
+		return nil, fmt.Errorf("invalid tenant middleware config: %v", err)
+	}
+	v, err := NewVerifier(options...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tenant middleware config: %v", err)
+	}
+	return v, nil
+}
+
+func (cfg Config) options() ([]Option, error) {
+	var options []Option
+	if cfg.DefaultSystemBaseUri != "" {
+		options = append(options, WithDefaultSystemBaseUri(cfg.DefaultSystemBaseUri))
+	}
+	if len(cfg.SignatureSecretKeys) > 0 && len(cfg.InboundKeys) > 0 {
+		return nil, errors.New("signatureSecretKeys and inboundKeys must not be used together")
+	}
+	if len(cfg.SignatureSecretKeys) > 0 && cfg.Scheme != nil {
+		return nil, errors.New("signatureSecretKeys and scheme must not be used together")
+	}
+	if len(cfg.InboundKeys) > 0 && cfg.Scheme != nil {
+		return nil, errors.New("inboundKeys and scheme must not be used together")
+	}
+	for i, key := range cfg.SignatureSecretKeys {
+		if len(key) == 0 {
+			return nil, fmt.Errorf("signature secret key %v is empty", i+1)
+		}
+	}
+	for i, key := range cfg.InboundKeys {
+		if len(key) == 0 {
+			return nil, fmt.Errorf("inbound key %v is empty", i+1)
+		}
+	}
+	if len(cfg.SignatureSecretKeys) > 0 {
+		options = append(options, WithSignatureSecretKeys(cfg.SignatureSecretKeys...))
+	}
+	if len(cfg.InboundKeys) > 0 {
+		options = append(options, WithSignatureSecretKeys(cfg.InboundKeys...))
+	}
+	if cfg.OutboundKey != nil {
+		options = append(options, WithOutboundKey(cfg.OutboundKey))
+	}
+	if cfg.Scheme != nil {
+		options = append(options, withSignatureScheme(cfg.Scheme))
+	}
+	if cfg.Logger != nil {
+		options = append(options, WithLogger(cfg.Logger))
+	}
+	if cfg.SignatureVersions != nil {
+		options = append(options, WithSignatureVersions(cfg.SignatureVersions...))
+	}
+	if cfg.InitiatorHeaders != nil {
+		options = append(options, WithInitiatorHeaderPriority(cfg.InitiatorHeaders...))
+	}
+	if cfg.MaxBaseUriLength < 0 {
+		return nil, fmt.Errorf("maxBaseUriLength must not be negative but is %v", cfg.MaxBaseUriLength)
+	}
+	if cfg.MaxBaseUriLength > 0 {
+		options = append(options, WithMaxBaseUriLength(cfg.MaxBaseUriLength))
+	}
+	if cfg.RequireBothIdentityHeaders {
+		options = append(options, WithRequireBothIdentityHeaders())
+	}
+	if cfg.AllowedHosts != nil {
+		options = append(options, WithAllowedHosts(cfg.AllowedHosts...))
+	}
+	return options, nil
+}
@@ -0,0 +1,128 @@
+package tenant_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestJSONConfig_NewFromConfig_CreatesWorkingVerifier(t *testing.T) {
+	configJSON := `{
+		"defaultSystemBaseUri": "https://default.example.com",
+		"signatureSecretKeys": ["` + base64.StdEncoding.EncodeToString(signatureKey) + `"],
+		"signatureVersions": [1, 2],
+		"requireBothIdentityHeaders": true,
+		"allowedHosts": ["sample.example.com"]
+	}`
+	var cfg tenant.Config
+	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	logSpy := loggerSpy{}
+	cfg.Logger = logSpy.logError
+
+	v, err := tenant.NewFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	v.Middleware(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+
+	req = signedRequest(t, "https://other.example.com", "a12be5")
+	v.Middleware(&handlerSpy).ServeHTTP(responseSpy, req)
+	if err := logSpy.assertLogContains("not allowed"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSeparateInboundAndOutboundKeys_NewFromConfig_SignsWithOutboundKey(t *testing.T) {
+	gateway, err := tenant.NewFromConfig(tenant.Config{InboundKeys: [][]byte{signatureKey}, OutboundKey: rotatedSignatureKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend, err := tenant.NewFromConfig(tenant.Config{InboundKeys: [][]byte{rotatedSignatureKey}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var outbound *http.Request
+	forward := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		header, err := gateway.OutgoingHeaders(req.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		outbound = httptest.NewRequest("GET", "/backend", nil)
+		for name, values := range header {
+			outbound.Header[name] = values
+		}
+	})
+	gateway.Middleware(forward).ServeHTTP(httptest.NewRecorder(), signedRequest(t, "https://sample.example.com", "a12be5"))
+	if outbound == nil {
+		t.Fatal("inbound request has been rejected")
+	}
+
+	rejectedSpy := responseSpy{httptest.NewRecorder()}
+	gateway.Middleware(&handlerSpy{}).ServeHTTP(rejectedSpy, outbound)
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	backend.Middleware(&handlerSpy).ServeHTTP(responseSpy, outbound)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+	if err := rejectedSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNoOutboundKey_OutgoingHeaders_ReturnsError(t *testing.T) {
+	v := tenant.MustNewVerifier(tenant.WithSignatureSecretKeys(signatureKey))
+	ctx := tenant.SetSystemBaseUri(tenant.SetId(context.Background(), "a12be5"), "https://sample.example.com")
+
+	if _, err := v.OutgoingHeaders(ctx); err == nil {
+		t.Error("expected an error because inbound keys must not be used for signing")
+	}
+}
+
+func TestInvalidConfig_NewFromConfig_ReturnsDescriptiveError(t *testing.T) {
+	testCases := []struct {
+		cfg      tenant.Config
+		expected string
+	}{
+		{tenant.Config{SignatureSecretKeys: [][]byte{signatureKey}, Scheme: tenant.HMACScheme(signatureKey)}, "must not be used together"},
+		{tenant.Config{SignatureSecretKeys: [][]byte{signatureKey, {}}}, "signature secret key 2 is empty"},
+		{tenant.Config{SignatureSecretKeys: [][]byte{signatureKey}, InboundKeys: [][]byte{signatureKey}}, "must not be used together"},
+		{tenant.Config{InboundKeys: [][]byte{{}}}, "inbound key 1 is empty"},
+		{tenant.Config{OutboundKey: []byte{}}, "outbound key"},
+		{tenant.Config{MaxBaseUriLength: -1}, "maxBaseUriLength"},
+		{tenant.Config{SignatureVersions: []int{3}}, "signature version 3"},
+		{tenant.Config{InitiatorHeaders: []string{"host"}}, "host"},
+	}
+	for _, tc := range testCases {
+		_, err := tenant.NewFromConfig(tc.cfg)
+		if err == nil {
+			t.Errorf("expected error containing '%v'", tc.expected)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("expected error containing '%v' but got '%v'", tc.expected, err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package tenant
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithCookieSource reads the tenant information from the signed cookie with the given name if the
+// request contains neither the header x-dv-baseuri nor the header x-dv-tenant-id. The headers take
+// precedence if both the headers and the cookie are present.
+//
+// The value of the cookie must be created with SignedCookieValue. The signature is verified with the
+// signature scheme of the middleware. Requests with a malformed, tampered or expired cookie are rejected with
+// status code 403. The systemBaseUri of the cookie is also used as initiatorSystemBaseUri
+// (cf. WithInitiatorFallbackToSystemBaseUri).
+func WithCookieSource(name string) Option {
+	return func(v *Verifier) error {
+		if name == "" {
+			return errors.New("cookie name must not be empty")
+		}
+		v.cookieName = name
+		return nil
+	}
+}
+
+// cookieMessageLabel separates the signed message of a cookie from the messages of other signatures which are
+// created with the same key, e.g. the signature of the tenant headers.
+const cookieMessageLabel = "dv-tenant-cookie\x00"
+
+// SignedCookieValue returns the value of a cookie which carries the given tenant information and which
+// is accepted by the middleware until expiry if WithCookieSource is used.
+//
+// The value consists of the base64url encoded systemBaseUri and tenantId, the expiry as Unix time in seconds
+// and the base64url encoded HMAC-SHA256 of the cookie message separated by dots. The cookie message is the
+// label "dv-tenant-cookie" followed by a zero byte, the length prefixed systemBaseUri and tenantId, e.g.
+// "26:https://sample.example.com6:a12be5", and the expiry. So neither the signature of the tenant headers
+// can be used as cookie nor vice versa.
+func SignedCookieValue(systemBaseUri, tenantId string, expiry time.Time, signatureSecretKey []byte) string {
+	seconds := expiry.Unix()
+	mac := hmac.New(sha256.New, signatureSecretKey)
+	mac.Write([]byte(cookieMessage(systemBaseUri, tenantId, seconds)))
+	return strings.Join([]string{
+		base64.RawURLEncoding.EncodeToString([]byte(systemBaseUri)),
+		base64.RawURLEncoding.EncodeToString([]byte(tenantId)),
+		strconv.FormatInt(seconds, 10),
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil)),
+	}, ".")
+}
+
+// cookieMessage returns the message which is signed by the signature of a cookie.
+func cookieMessage(systemBaseUri, tenantId string, expiry int64) string {
+	return cookieMessageLabel +
+		strconv.Itoa(len(systemBaseUri)) + ":" + systemBaseUri +
+		strconv.Itoa(len(tenantId)) + ":" + tenantId +
+		strconv.FormatInt(expiry, 10)
+}
+
+// tenantCookie returns the cookie which carries the tenant information if the request doesn't contain tenant headers.
+func (v *Verifier) tenantCookie(req *http.Request) (*http.Cookie, bool) {
+	if v.cookieName == "" || headerValue(req.Header, systemBaseUriHeader) != "" || headerValue(req.Header, tenantIdHeader) != "" {
+		return nil, false
+	}
+	cookie, err := req.Cookie(v.cookieName)
+	return cookie, err == nil
+}
+
+func (v *Verifier) validateCookie(req *http.Request, cookie *http.Cookie) (TenantInfo, sourceClaims, *verificationError) {
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 4 {
+		return TenantInfo{}, sourceClaims{}, &verificationError{http.StatusForbidden, fmt.Sprintf("cookie '%v' is malformed", cookie.Name), nil}
+	}
+	expiry, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return TenantInfo{}, sourceClaims{}, &verificationError{http.StatusForbidden, fmt.Sprintf("parsing expiry of cookie '%v' as Unix time because: %v", cookie.Name, err), nil}
+	}
+	values := make([][]byte, 0, 3)
+	for _, part := range []string{parts[0], parts[1], parts[3]} {
+		value, err := base64.RawURLEncoding.DecodeString(part)
+		if err != nil {
+			return TenantInfo{}, sourceClaims{}, &verificationError{http.StatusForbidden, fmt.Sprintf("decoding cookie '%v' as base 64 data because: %v", cookie.Name, err), nil}
+		}
+		values = append(values, value)
+	}
+	systemBaseUri, tenantId, signature := string(values[0]), string(values[1]), values[2]
+	if tenantId == "" {
+		return TenantInfo{}, sourceClaims{}, &verificationError{http.StatusForbidden, fmt.Sprintf("cookie '%v' doesn't contain a tenant id", cookie.Name), nil}
+	}
+	scheme, vErr := v.signatureScheme(req.Context(), tenantId)
+	if vErr != nil {
+		return TenantInfo{}, sourceClaims{}, vErr
+	}
+	if !scheme.Verify([]byte(cookieMessage(systemBaseUri, tenantId, expiry)), signature) {
+		return TenantInfo{}, sourceClaims{}, &verificationError{http.StatusForbidden, fmt.Sprintf("signature of cookie '%v' is not valid for SystemBaseUri '%v' and TenantId '%v'", cookie.Name, systemBaseUri, v.loggedTenantId(tenantId)), ErrInvalidSignature}
+	}
+	if !v.now().Before(time.Unix(expiry, 0)) {
+		return TenantInfo{}, sourceClaims{}, &verificationError{http.StatusForbidden, fmt.Sprintf("cookie '%v' expired at %v", cookie.Name, time.Unix(expiry, 0).UTC()), nil}
+	}
+	signed := sourceClaims{source: sourceCookie, systemBaseUri: systemBaseUri, tenantId: tenantId}
+	if systemBaseUri == "" {
+		systemBaseUri = v.defaultSystemBaseUri
+	}
+	var initiatorSystemBaseUri string
+	if v.initiatorFallback {
+		initiatorSystemBaseUri = systemBaseUri
+	}
+	return TenantInfo{
+		TenantId:               tenantId,
+		SystemBaseUri:          normalizeBaseUri(systemBaseUri),
+		InitiatorSystemBaseUri: normalizeBaseUri(initiatorSystemBaseUri),
+		SignatureVerified:      true,
+	}, signed, nil
+}
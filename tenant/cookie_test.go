@@ -0,0 +1,207 @@
+package tenant_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+const tenantCookieName = "dv-tenant"
+
+var cookieExpiry = time.Now().Add(time.Hour)
+
+func TestValidSignedCookie_AddsTenantToContext(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/myresource/sub", nil)
+	req.AddCookie(&http.Cookie{Name: tenantCookieName, Value: tenant.SignedCookieValue("https://sample.example.com", "a12be5", cookieExpiry, signatureKey)})
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithCookieSource(tenantCookieName))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertBaseUriIs("https://sample.example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModifiedSignedCookie_Returns403(t *testing.T) {
+	value := tenant.SignedCookieValue("https://sample.example.com", "a12be5", cookieExpiry, signatureKey)
+	parts := strings.Split(value, ".")
+	forged := tenant.SignedCookieValue("https://sample.example.com", "other", cookieExpiry, signatureKey)
+	parts[1] = strings.Split(forged, ".")[1]
+	req, _ := http.NewRequest("GET", "/myresource/sub", nil)
+	req.AddCookie(&http.Cookie{Name: tenantCookieName, Value: strings.Join(parts, ".")})
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithCookieSource(tenantCookieName))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+}
+
+func TestMalformedSignedCookie_Returns403(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/myresource/sub", nil)
+	req.AddCookie(&http.Cookie{Name: tenantCookieName, Value: "a12be5"})
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithCookieSource(tenantCookieName))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHeadersAndSignedCookie_HeadersTakePrecedence(t *testing.T) {
+	req := signedRequest(t, "https://header.example.com", "header")
+	req.AddCookie(&http.Cookie{Name: tenantCookieName, Value: tenant.SignedCookieValue("https://cookie.example.com", "cookie", cookieExpiry, signatureKey)})
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithCookieSource(tenantCookieName))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("header"); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertBaseUriIs("https://header.example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSignedCookieForDisallowedHost_Returns403(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/myresource/sub", nil)
+	req.AddCookie(&http.Cookie{Name: tenantCookieName, Value: tenant.SignedCookieValue("https://evil.example.com", "a12be5", cookieExpiry, signatureKey)})
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithCookieSource(tenantCookieName), tenant.WithAllowedHosts("sample.example.com"))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+}
+
+func TestForgedCookies_Returns403(t *testing.T) {
+	expiry := strconv.FormatInt(cookieExpiry.Unix(), 10)
+	valid := strings.Split(tenant.SignedCookieValue("https://a.example.comb", "1", cookieExpiry, signatureKey), ".")
+	extended := tenant.SignedCookieValue("https://sample.example.com", "a12be5", cookieExpiry, signatureKey)
+	extendedParts := strings.Split(extended, ".")
+	extendedParts[2] = strconv.FormatInt(cookieExpiry.Add(24*time.Hour).Unix(), 10)
+	headerSignature, _ := base64.StdEncoding.DecodeString(base64Signature("https://sample.example.coma12be5", signatureKey))
+	testCases := map[string]string{
+		"header signature as cookie": strings.Join([]string{
+			base64.RawURLEncoding.EncodeToString([]byte("https://sample.example.com")),
+			base64.RawURLEncoding.EncodeToString([]byte("a12be5")),
+			expiry,
+			base64.RawURLEncoding.EncodeToString(headerSignature),
+		}, "."),
+		"shifted boundary": strings.Join([]string{
+			base64.RawURLEncoding.EncodeToString([]byte("https://a.example.com")),
+			base64.RawURLEncoding.EncodeToString([]byte("b1")),
+			valid[2],
+			valid[3],
+		}, "."),
+		"extended expiry": strings.Join(extendedParts, "."),
+		"expired":         tenant.SignedCookieValue("https://sample.example.com", "a12be5", time.Now().Add(-time.Second), signatureKey),
+		"without expiry":  strings.Join([]string{valid[0], valid[1], valid[3]}, "."),
+	}
+	for name, value := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/myresource/sub", nil)
+			req.AddCookie(&http.Cookie{Name: tenantCookieName, Value: value})
+			handlerSpy := handlerSpy{}
+			responseSpy := responseSpy{httptest.NewRecorder()}
+
+			tenant.AddToCtx("", signatureKey, nil, tenant.WithCookieSource(tenantCookieName))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+			if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+				t.Error(err)
+			}
+			if handlerSpy.hasBeenCalled {
+				t.Error("inner handler should not have been called")
+			}
+		})
+	}
+}
+
+func TestCookieSignature_AsHeaderSignature_Returns403(t *testing.T) {
+	parts := strings.Split(tenant.SignedCookieValue("https://sample.example.com", "a12be5", cookieExpiry, signatureKey), ".")
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[3])
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(signatureHeader, base64.StdEncoding.EncodeToString(signature))
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil)(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpiredCookieAccordingToClock_Returns403(t *testing.T) {
+	expiry := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	value := tenant.SignedCookieValue("https://sample.example.com", "a12be5", expiry, signatureKey)
+	for name, tc := range map[string]struct {
+		now  time.Time
+		want int
+	}{
+		"before expiry": {expiry.Add(-time.Second), http.StatusOK},
+		"at expiry":     {expiry, http.StatusForbidden},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/myresource/sub", nil)
+			req.AddCookie(&http.Cookie{Name: tenantCookieName, Value: value})
+			responseSpy := responseSpy{httptest.NewRecorder()}
+			clock := func() time.Time { return tc.now }
+
+			tenant.AddToCtx("", signatureKey, nil, tenant.WithCookieSource(tenantCookieName), tenant.WithClock(clock))(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+			if err := responseSpy.assertStatusCodeIs(tc.want); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestValidSignedCookie_ReplayProtectionOptions_AddsTenantToContext(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/myresource/sub", nil)
+	req.AddCookie(&http.Cookie{Name: tenantCookieName, Value: tenant.SignedCookieValue("https://sample.example.com", "a12be5", cookieExpiry, signatureKey)})
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil,
+		tenant.WithCookieSource(tenantCookieName),
+		tenant.WithSignedExpiryHeader("x-dv-expires"),
+		tenant.WithTimestampInMessage("x-dv-timestamp", time.RFC3339),
+		tenant.WithNonceStore(tenant.NewMemoryNonceStore()),
+	)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+}
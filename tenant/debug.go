@@ -0,0 +1,30 @@
+package tenant
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// DebugHandler returns a handler which responds with the tenant information of the request context as JSON, e.g.
+//
+//	{"tenantId":"a12be5","systemBaseUri":"https://sample.example.com","initiatorSystemBaseUri":"https://sample.example.com","signatureVerified":true}
+//
+// Mount it behind the middleware to check which tenant information the middleware has extracted from a request.
+// The response never contains signatures or keys. Requests without tenant information on the context are
+// answered with status code 404.
+func DebugHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if _, err := IdFromCtx(req.Context()); err != nil {
+			http.NotFound(rw, req)
+			return
+		}
+		info := InfoFromCtx(req.Context())
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(struct {
+			TenantId               string `json:"tenantId"`
+			SystemBaseUri          string `json:"systemBaseUri"`
+			InitiatorSystemBaseUri string `json:"initiatorSystemBaseUri,omitempty"`
+			SignatureVerified      bool   `json:"signatureVerified"`
+		}{info.TenantId, info.SystemBaseUri, info.InitiatorSystemBaseUri, info.SignatureVerified})
+	})
+}
@@ -0,0 +1,52 @@
+package tenant_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestTenantContext_DebugHandler_ReturnsTenantInfoAsJson(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(forwardedHeader, "host=initiator.example.com")
+	rec := httptest.NewRecorder()
+
+	tenant.AddToCtx("", signatureKey, nil)(tenant.DebugHandler()).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("got wrong status code: got %v want %v", rec.Code, http.StatusOK)
+	}
+	if got, want := rec.Header().Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("got wrong content type: got %v want %v", got, want)
+	}
+	want := `{"tenantId":"a12be5","systemBaseUri":"https://sample.example.com","initiatorSystemBaseUri":"https://initiator.example.com","signatureVerified":true}` + "\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("got wrong body: got %v want %v", got, want)
+	}
+}
+
+func TestSetTenantContext_DebugHandler_ReturnsTenantInfoAsJson(t *testing.T) {
+	ctx := tenant.SetSystemBaseUri(tenant.SetId(context.Background(), "a12be5"), "https://sample.example.com")
+	req := httptest.NewRequest("GET", "/debug/tenant", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	tenant.DebugHandler().ServeHTTP(rec, req)
+
+	want := `{"tenantId":"a12be5","systemBaseUri":"https://sample.example.com","signatureVerified":false}` + "\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("got wrong body: got %v want %v", got, want)
+	}
+}
+
+func TestNoTenantContext_DebugHandler_Returns404(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	tenant.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/tenant", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("got wrong status code: got %v want %v", rec.Code, http.StatusNotFound)
+	}
+}
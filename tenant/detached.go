@@ -0,0 +1,27 @@
+package tenant
+
+import (
+	"net/http"
+)
+
+// VerifyDetached verifies a signature which has been transmitted separately from the signed message,
+// e.g. in a message bus. The signature must be the base64 encoded HMAC-SHA256 of message computed with key.
+//
+// The returned error wraps the same sentinel errors as the errors of ValidateRequest, i.e.
+// ErrMissingSignatureKey, ErrMissingSignature, ErrMalformedSignature and ErrInvalidSignature.
+func VerifyDetached(message []byte, signatureBase64 string, key []byte) error {
+	if len(key) == 0 {
+		return &verificationError{http.StatusInternalServerError, "validating detached signature because secret signature key has not been configured", ErrMissingSignatureKey}
+	}
+	if signatureBase64 == "" {
+		return &verificationError{http.StatusForbidden, "detached signature is missing", ErrMissingSignature}
+	}
+	signature, err := decodeSignature(signatureBase64)
+	if err != nil {
+		return &verificationError{http.StatusForbidden, "decoding detached signature as base 64 data because: " + err.Error(), ErrMalformedSignature}
+	}
+	if !signatureIsValidForAnyKey(message, signature, [][]byte{key}) {
+		return &verificationError{http.StatusForbidden, "detached signature is not valid", ErrInvalidSignature}
+	}
+	return nil
+}
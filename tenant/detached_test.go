@@ -0,0 +1,49 @@
+package tenant_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestValidDetachedSignature_VerifyDetached_ReturnsNil(t *testing.T) {
+	message := []byte(`{"event":"created","tenantId":"a12be5"}`)
+
+	if err := tenant.VerifyDetached(message, base64Signature(string(message), signatureKey), signatureKey); err != nil {
+		t.Errorf("expected valid signature but got '%v'", err)
+	}
+}
+
+func TestInvalidDetachedSignature_VerifyDetached_ReturnsTypedError(t *testing.T) {
+	message := []byte(`{"event":"created","tenantId":"a12be5"}`)
+	testCases := []struct {
+		name      string
+		signature string
+		key       []byte
+		expected  error
+	}{
+		{"tampered message", base64Signature(`{"event":"deleted","tenantId":"a12be5"}`, signatureKey), signatureKey, tenant.ErrInvalidSignature},
+		{"other key", base64Signature(string(message), rotatedSignatureKey), signatureKey, tenant.ErrInvalidSignature},
+		{"malformed", "abc+(9-!", signatureKey, tenant.ErrMalformedSignature},
+		{"missing", "", signatureKey, tenant.ErrMissingSignature},
+		{"no key", base64Signature(string(message), signatureKey), nil, tenant.ErrMissingSignatureKey},
+	}
+	for _, tc := range testCases {
+		err := tenant.VerifyDetached(message, tc.signature, tc.key)
+		if !errors.Is(err, tc.expected) {
+			t.Errorf("%v: expected error '%v' but got '%v'", tc.name, tc.expected, err)
+		}
+	}
+}
+
+func TestInvalidHeaderSignature_ValidateRequest_ReturnsSameTypedError(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(tenantIdHeader, "other")
+
+	_, _, err := tenant.ValidateRequest(req, signatureKey)
+
+	if !errors.Is(err, tenant.ErrInvalidSignature) {
+		t.Errorf("expected error '%v' but got '%v'", tenant.ErrInvalidSignature, err)
+	}
+}
@@ -0,0 +1,45 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// WithSignedExpiryHeader requires the header with the given name to contain the time at which the request
+// expires as Unix time in seconds, e.g. "1700000000". The value of the header is appended to the signed
+// message (use SignRequest with the same option to sign such requests).
+//
+// Requests without a valid expiry or which have expired according to the clock configured by WithClock
+// are rejected with status code 403. Otherwise the expiry is set as deadline of the context which is
+// passed to the next handler so that downstream work is bounded. For a JWT (cf. WithJWTSource) the
+// claim exp is used instead of the header; JWTs without it are rejected with status code 401. Cookies
+// (cf. WithCookieSource) contain their own signed expiry, so the header isn't required for them.
+func WithSignedExpiryHeader(name string) Option {
+	return func(v *Verifier) error {
+		if name == "" {
+			return errors.New("expiry header name must not be empty")
+		}
+		v.signedExpiryHeader = name
+		return nil
+	}
+}
+
+// signedExpiry returns the expiry of the request and rejects requests which have expired.
+func (v *Verifier) signedExpiry(req *http.Request) (time.Time, *verificationError) {
+	value := headerValue(req.Header, v.signedExpiryHeader)
+	if value == "" {
+		return time.Time{}, &verificationError{http.StatusForbidden, fmt.Sprintf("expiry header '%v' is missing", v.signedExpiryHeader), nil}
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, &verificationError{http.StatusForbidden, fmt.Sprintf("parsing expiry header '%v' with value '%v' as Unix time because: %v", v.signedExpiryHeader, value, err), nil}
+	}
+	expiry := time.Unix(seconds, 0)
+	if !v.now().Before(expiry) {
+		return time.Time{}, &verificationError{http.StatusForbidden, fmt.Sprintf("request expired at %v", expiry.UTC()), nil}
+	}
+	return expiry, nil
+}
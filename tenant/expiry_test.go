@@ -0,0 +1,88 @@
+package tenant_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+const expiryHeader = "x-dv-expires"
+
+func TestNotExpiredRequest_SignedExpiryHeader_SetsDeadline(t *testing.T) {
+	expiry := time.Now().Add(time.Minute).Truncate(time.Second)
+	req := expiringRequest(t, expiry)
+	var deadline time.Time
+	var hasDeadline bool
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		deadline, hasDeadline = r.Context().Deadline()
+	})
+	rec := httptest.NewRecorder()
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignedExpiryHeader(expiryHeader))(next).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("got wrong status code: got %v want %v", rec.Code, http.StatusOK)
+	}
+	if !hasDeadline || !deadline.Equal(expiry) {
+		t.Errorf("got wrong deadline: got %v want %v", deadline, expiry)
+	}
+}
+
+func TestExpiredRequest_SignedExpiryHeader_Returns403(t *testing.T) {
+	req := expiringRequest(t, time.Now().Add(-time.Minute))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignedExpiryHeader(expiryHeader))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+}
+
+func TestTamperedExpiry_SignedExpiryHeader_Returns403(t *testing.T) {
+	req := expiringRequest(t, time.Now().Add(time.Minute))
+	req.Header.Set(expiryHeader, strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignedExpiryHeader(expiryHeader))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMissingExpiry_SignedExpiryHeader_Returns403(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignedExpiryHeader(expiryHeader))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func expiringRequest(t *testing.T, expiry time.Time) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(systemBaseUriHeader, "https://sample.example.com")
+	req.Header.Set(tenantIdHeader, "a12be5")
+	req.Header.Set(expiryHeader, strconv.FormatInt(expiry.Unix(), 10))
+	if err := tenant.SignRequest(req, signatureKey, tenant.WithSignedExpiryHeader(expiryHeader)); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
@@ -0,0 +1,85 @@
+package tenant
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+)
+
+// WithExpvar publishes counters of the outcomes of the verification with the expvar package, so that they are
+// available e.g. at /debug/vars. The following integers are published with the given prefix:
+//
+//	<prefix>.accepted     requests which have been accepted
+//	<prefix>.rejected     requests which have been rejected for any reason
+//	<prefix>.malformed    rejected requests with a malformed signature (cf. ErrMalformedSignature)
+//	<prefix>.missing_key  rejected requests because no signature key has been configured (cf. ErrMissingSignatureKey)
+//
+// The counters are shared by all middlewares which use the same prefix.
+func WithExpvar(prefix string) Option {
+	return func(v *Verifier) error {
+		if prefix == "" {
+			return errors.New("expvar prefix must not be empty")
+		}
+		counters := &expvarCounters{}
+		for _, c := range []struct {
+			name string
+			ptr  **expvar.Int
+		}{
+			{"accepted", &counters.accepted},
+			{"rejected", &counters.rejected},
+			{"malformed", &counters.malformed},
+			{"missing_key", &counters.missingKey},
+		} {
+			i, err := expvarInt(prefix + "." + c.name)
+			if err != nil {
+				return err
+			}
+			*c.ptr = i
+		}
+		v.expvarCounters = counters
+		return nil
+	}
+}
+
+type expvarCounters struct {
+	accepted   *expvar.Int
+	rejected   *expvar.Int
+	malformed  *expvar.Int
+	missingKey *expvar.Int
+}
+
+// expvarInt returns the integer which has been published with name or publishes a new one.
+// expvar.Publish panics if the name has already been published, e.g. by another middleware.
+func expvarInt(name string) (*expvar.Int, error) {
+	existing := expvar.Get(name)
+	if existing == nil {
+		return expvar.NewInt(name), nil
+	}
+	i, ok := existing.(*expvar.Int)
+	if !ok {
+		return nil, fmt.Errorf("expvar '%v' has already been published with type %T", name, existing)
+	}
+	return i, nil
+}
+
+// countAccepted increments the counter of accepted requests. A nil expvarCounters doesn't count anything.
+func (c *expvarCounters) countAccepted() {
+	if c == nil {
+		return
+	}
+	c.accepted.Add(1)
+}
+
+// countRejected increments the counter of rejected requests and the counter for the reason of the rejection.
+func (c *expvarCounters) countRejected(err error) {
+	if c == nil {
+		return
+	}
+	c.rejected.Add(1)
+	switch {
+	case errors.Is(err, ErrMalformedSignature):
+		c.malformed.Add(1)
+	case errors.Is(err, ErrMissingSignatureKey):
+		c.missingKey.Add(1)
+	}
+}
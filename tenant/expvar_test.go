@@ -0,0 +1,55 @@
+package tenant_test
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestRequestOutcomes_Expvar_IncrementsCounters(t *testing.T) {
+	malformed := signedRequest(t, "https://sample.example.com", "a12be5")
+	malformed.Header.Set(signatureHeader, "not base 64")
+	tampered := signedRequest(t, "https://sample.example.com", "a12be5")
+	tampered.Header.Set(tenantIdHeader, "other")
+
+	handler := tenant.AddToCtx("", signatureKey, nil, tenant.WithExpvar("tenant_test_outcomes"))(&handlerSpy{})
+	for _, req := range []*http.Request{signedRequest(t, "https://sample.example.com", "a12be5"), malformed, tampered} {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	tenant.AddToCtx("", nil, nil, tenant.WithExpvar("tenant_test_outcomes"))(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), signedRequest(t, "https://sample.example.com", "a12be5"))
+
+	for name, want := range map[string]int64{"accepted": 1, "rejected": 3, "malformed": 1, "missing_key": 1} {
+		if err := assertExpvarIs("tenant_test_outcomes."+name, want); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestEmptyPrefix_Expvar_ReturnsError(t *testing.T) {
+	if _, err := tenant.NewVerifier(tenant.WithExpvar("")); err == nil {
+		t.Error("expected an error for an empty prefix")
+	}
+}
+
+func TestPrefixPublishedWithOtherType_Expvar_ReturnsError(t *testing.T) {
+	expvar.NewString("tenant_test_string.accepted")
+
+	if _, err := tenant.NewVerifier(tenant.WithExpvar("tenant_test_string")); err == nil {
+		t.Error("expected an error for a prefix which has been published with another type")
+	}
+}
+
+func assertExpvarIs(name string, want int64) error {
+	i, ok := expvar.Get(name).(*expvar.Int)
+	if !ok {
+		return fmt.Errorf("expvar '%v' has not been published as integer", name)
+	}
+	if got := i.Value(); got != want {
+		return fmt.Errorf("got wrong value for expvar '%v': got %v want %v", name, got, want)
+	}
+	return nil
+}
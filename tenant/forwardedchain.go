@@ -0,0 +1,76 @@
+package tenant
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// WithSignForwardedChain requires the complete chain of forwarded hosts to be part of the signed message, so
+// that a proxy can't add another hop without invalidating the signature. The chain consists of the hosts of all
+// lines of the first forwarded header which is present in the order of WithInitiatorHeaderPriority, i.e. the host
+// parameters of the header Forwarded or the values of the header x-forwarded-host. The hosts are trimmed,
+// unquoted, converted to lowercase and joined by commas, e.g. "initiator.example.com,proxy.example.com".
+// An empty chain is signed if no forwarded header is present. Use SignRequest to sign such requests.
+//
+// The initiatorSystemBaseUri is still derived from the first host of the chain.
+func WithSignForwardedChain() Option {
+	return func(v *Verifier) error {
+		v.signForwardedChain = true
+		return nil
+	}
+}
+
+// forwardedChain returns the canonical hosts of the first forwarded header of req which is present.
+func (v *Verifier) forwardedChain(req *http.Request) []string {
+	for _, header := range v.initiatorHeaders {
+		var hosts []string
+		for _, value := range headerValues(req.Header, header) {
+			switch header {
+			case forwardedHeader:
+				hosts = append(hosts, forwardedHosts(value)...)
+			case xForwardedHostHeader:
+				for _, host := range strings.Split(value, commaDelimiter) {
+					hosts = append(hosts, strings.ToLower(strings.TrimSpace(host)))
+				}
+			}
+		}
+		if len(hosts) > 0 {
+			return hosts
+		}
+	}
+	return nil
+}
+
+// forwardedHosts returns the canonical host parameters of the elements of a Forwarded header (cf. RFC 7239).
+// Elements without host parameter are skipped.
+func forwardedHosts(forwardedValue string) []string {
+	var hosts []string
+	for _, element := range strings.Split(forwardedValue, commaDelimiter) {
+		for _, pair := range strings.Split(element, colonDelimiter) {
+			pair = strings.TrimSpace(pair)
+			if len(pair) >= len(forwardedHostPattern) && strings.EqualFold(pair[:len(forwardedHostPattern)], forwardedHostPattern) {
+				host := strings.Trim(strings.TrimSpace(pair[len(forwardedHostPattern):]), `"`)
+				hosts = append(hosts, strings.ToLower(host))
+			}
+		}
+	}
+	return hosts
+}
+
+// headerValues returns all values of the header with the given name regardless of the case of the name.
+// The values of keys which differ only in case are ordered by key so that the result is deterministic.
+func headerValues(header http.Header, name string) []string {
+	var keys []string
+	for key := range header {
+		if strings.EqualFold(key, name) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var values []string
+	for _, key := range keys {
+		values = append(values, header[key]...)
+	}
+	return values
+}
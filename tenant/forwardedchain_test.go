@@ -0,0 +1,74 @@
+package tenant_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func forwardedChainRequest(t *testing.T, forwarded string) *http.Request {
+	t.Helper()
+	req := methodAndPathSignedRequest(t, "GET", "/callback")
+	req.Header.Set(forwardedHeader, forwarded)
+	if err := tenant.SignRequest(req, signatureKey, tenant.WithSignForwardedChain()); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestSignedChain_SignForwardedChain_UsesFirstHostAsInitiator(t *testing.T) {
+	req := forwardedChainRequest(t, `host="Initiator.example.com", for=192.0.2.60;host=proxy.example.com`)
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignForwardedChain())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertInitiatorSystemBaseUriIs("https://Initiator.example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestChain_SignForwardedChain_SignsCanonicalChain(t *testing.T) {
+	req := methodAndPathSignedRequest(t, "GET", "/callback")
+	req.Header.Set(xForwardedHostHeader, " Initiator.example.com , proxy.example.com")
+	req.Header.Set(signatureHeader, base64Signature("https://sample.example.coma12be5initiator.example.com,proxy.example.com", signatureKey))
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignForwardedChain())(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAddedHop_SignForwardedChain_Returns403(t *testing.T) {
+	testCases := map[string]func(req *http.Request){
+		"appended hop": func(req *http.Request) {
+			req.Header.Set(forwardedHeader, "host=initiator.example.com, host=proxy.example.com")
+		},
+		"additional line": func(req *http.Request) { req.Header.Add(forwardedHeader, "host=proxy.example.com") },
+		"replaced hop":    func(req *http.Request) { req.Header.Set(forwardedHeader, "host=evil.example.com") },
+	}
+	for name, tamper := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := forwardedChainRequest(t, "host=initiator.example.com")
+			tamper(req)
+			handlerSpy := handlerSpy{}
+			responseSpy := responseSpy{httptest.NewRecorder()}
+
+			tenant.AddToCtx("", signatureKey, nil, tenant.WithSignForwardedChain())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+			if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+				t.Error(err)
+			}
+			if handlerSpy.hasBeenCalled {
+				t.Error("inner handler should not have been called")
+			}
+		})
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/d-velop/dvelop-sdk-go/tenant
 
-go 1.12
+go 1.13
+
+require github.com/gorilla/mux v1.8.1
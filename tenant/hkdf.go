@@ -0,0 +1,113 @@
+package tenant
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// derivedKeyLength is the length of the keys derived by DeriveTenantKey.
+const derivedKeyLength = sha256.Size
+
+// HKDFKeyProvider is a KeyProvider which derives the signature key of each tenant from a master secret.
+// So every tenant has its own key and a key which leaks can't be used to sign requests for other tenants.
+//
+// The key of a tenant is derived with HKDF-SHA256 (RFC 5869) as described in DeriveTenantKey.
+//
+// Keys are derived for every request unless they have been cached with WarmKeys.
+type HKDFKeyProvider struct {
+	derivations  int64 // accessed atomically, first field for 64-bit alignment on 32-bit platforms
+	masterSecret []byte
+	salt         []byte
+
+	mu         sync.RWMutex // protects the following fields
+	warmedKeys map[string][]byte
+}
+
+// NewHKDFKeyProvider creates a new HKDFKeyProvider which derives the keys from the given master secret and salt.
+// The salt is optional and may be nil.
+func NewHKDFKeyProvider(masterSecret, salt []byte) *HKDFKeyProvider {
+	return &HKDFKeyProvider{
+		masterSecret: masterSecret,
+		salt:         salt,
+	}
+}
+
+// Keys returns the key derived for the given tenant.
+func (p *HKDFKeyProvider) Keys(ctx context.Context, tenantId string) ([][]byte, error) {
+	if len(p.masterSecret) == 0 {
+		return nil, errors.New("deriving tenant key because master secret has not been configured")
+	}
+	p.mu.RLock()
+	key, warmed := p.warmedKeys[tenantId]
+	p.mu.RUnlock()
+	if warmed {
+		return [][]byte{key}, nil
+	}
+	return [][]byte{p.derive(tenantId)}, nil
+}
+
+// WarmKeys derives the keys of the given tenants and caches them so that requests of these tenants
+// don't have to derive the key. Only the keys of the given tenants are cached, so the memory isn't
+// exhausted by requests which claim arbitrary tenant ids.
+func (p *HKDFKeyProvider) WarmKeys(tenantIds ...string) {
+	if len(p.masterSecret) == 0 {
+		return
+	}
+	keys := make(map[string][]byte, len(tenantIds))
+	for _, tenantId := range tenantIds {
+		keys[tenantId] = p.derive(tenantId)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.warmedKeys == nil {
+		p.warmedKeys = make(map[string][]byte, len(keys))
+	}
+	for tenantId, key := range keys {
+		p.warmedKeys[tenantId] = key
+	}
+}
+
+// Derivations returns the number of keys which have been derived by the provider.
+func (p *HKDFKeyProvider) Derivations() int64 {
+	return atomic.LoadInt64(&p.derivations)
+}
+
+func (p *HKDFKeyProvider) derive(tenantId string) []byte {
+	atomic.AddInt64(&p.derivations, 1)
+	return DeriveTenantKey(p.masterSecret, p.salt, tenantId)
+}
+
+// DeriveTenantKey derives the signature key of a tenant from the master secret.
+//
+// The derivation uses HKDF-SHA256 (RFC 5869) with the following parameters:
+//   - input keying material: the master secret
+//   - salt: the given salt; an empty salt is replaced by 32 zero bytes as specified by RFC 5869
+//   - info: the tenant id as sent in the header x-dv-tenant-id (UTF-8 encoded, without any prefix)
+//   - output length: 32 bytes
+//
+// Signers must derive the key with exactly these parameters and sign the request with the derived key.
+func DeriveTenantKey(masterSecret, salt []byte, tenantId string) []byte {
+	if len(salt) == 0 {
+		salt = make([]byte, sha256.Size)
+	}
+	// extract
+	extractor := hmac.New(sha256.New, salt)
+	extractor.Write(masterSecret)
+	pseudoRandomKey := extractor.Sum(nil)
+
+	// expand
+	var key, block []byte
+	for counter := byte(1); len(key) < derivedKeyLength; counter++ {
+		expander := hmac.New(sha256.New, pseudoRandomKey)
+		expander.Write(block)
+		expander.Write([]byte(tenantId))
+		expander.Write([]byte{counter})
+		block = expander.Sum(nil)
+		key = append(key, block...)
+	}
+	return key[:derivedKeyLength]
+}
@@ -0,0 +1,116 @@
+package tenant_test
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+var masterSecret = []byte{81, 56, 203, 18, 210, 32, 84, 159, 7, 196, 42, 233, 118, 250, 61, 140, 15, 97, 172, 66, 201, 33, 5, 91, 240, 129, 74, 188, 23, 164, 99, 12}
+
+func TestDeriveTenantKey_MatchesRFC5869TestVector(t *testing.T) {
+	ikm, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+
+	key := tenant.DeriveTenantKey(ikm, salt, string(info))
+
+	// first 32 bytes of the OKM of test case 1 of RFC 5869
+	if got, want := hex.EncodeToString(key), "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf"; got != want {
+		t.Errorf("got wrong key: got %v want %v", got, want)
+	}
+}
+
+func TestRequestSignedWithDerivedKey_HKDFKeyProvider_CallsInnerHandler(t *testing.T) {
+	req := signedRequestWithKey(t, "https://sample.example.com", "a12be5", tenant.DeriveTenantKey(masterSecret, nil, "a12be5"))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", nil, nil, tenant.WithKeyProvider(tenant.NewHKDFKeyProvider(masterSecret, nil)))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRequestSignedWithKeyOfOtherTenant_HKDFKeyProvider_Returns403(t *testing.T) {
+	req := signedRequestWithKey(t, "https://sample.example.com", "a12be5", tenant.DeriveTenantKey(masterSecret, nil, "other"))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", nil, nil, tenant.WithKeyProvider(tenant.NewHKDFKeyProvider(masterSecret, nil)))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeriveTenantKey_DiffersPerTenant(t *testing.T) {
+	provider := tenant.NewHKDFKeyProvider(masterSecret, nil)
+	keys1, _ := provider.Keys(context.Background(), "1")
+	keys2, _ := provider.Keys(context.Background(), "2")
+
+	if string(keys1[0]) == string(keys2[0]) {
+		t.Error("expected different keys for different tenants")
+	}
+}
+
+func signedRequestWithKey(t *testing.T, systemBaseUri, tenantId string, key []byte) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(systemBaseUriHeader, systemBaseUri)
+	req.Header.Set(tenantIdHeader, tenantId)
+	req.Header.Set(signatureHeader, base64Signature(systemBaseUri+tenantId, key))
+	return req
+}
+
+func TestWarmedTenants_WarmKeys_VerifiesWithoutDerivation(t *testing.T) {
+	provider := tenant.NewHKDFKeyProvider(masterSecret, nil)
+	v, err := tenant.NewVerifier(tenant.WithKeyProvider(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v.WarmKeys("a12be5", "b34cd6")
+	warmed := provider.Derivations()
+	for _, tenantId := range []string{"a12be5", "b34cd6", "a12be5"} {
+		responseSpy := responseSpy{httptest.NewRecorder()}
+		v.Middleware(&handlerSpy{}).ServeHTTP(responseSpy, signedRequestWithKey(t, "https://sample.example.com", tenantId, tenant.DeriveTenantKey(masterSecret, nil, tenantId)))
+
+		if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if warmed != 2 {
+		t.Errorf("got wrong number of derivations while warming: got %v want %v", warmed, 2)
+	}
+	if got := provider.Derivations(); got != warmed {
+		t.Errorf("got wrong number of derivations after warming: got %v want %v", got, warmed)
+	}
+}
+
+func TestNotWarmedTenant_WarmKeys_DerivesKey(t *testing.T) {
+	provider := tenant.NewHKDFKeyProvider(masterSecret, nil)
+	v, err := tenant.NewVerifier(tenant.WithKeyProvider(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.WarmKeys("a12be5")
+
+	v.Middleware(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), signedRequestWithKey(t, "https://sample.example.com", "other", tenant.DeriveTenantKey(masterSecret, nil, "other")))
+
+	if got := provider.Derivations(); got != 2 {
+		t.Errorf("got wrong number of derivations: got %v want %v", got, 2)
+	}
+}
@@ -0,0 +1,37 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+)
+
+// Impersonate returns a new context with the tenant information of info instead of the tenant information
+// of ctx, e.g. for support tooling which acts on behalf of a tenant for a single operation. The context is
+// marked as impersonated (cf. IsImpersonated) so that the impersonation can be detected and audited.
+//
+// The TenantId and the SystemBaseUri of info are required. If info doesn't contain an InitiatorSystemBaseUri
+// the SystemBaseUri is used. The values are set as given and the context reports them as not verified by a
+// signature (cf. SignatureVerifiedFromCtx) regardless of info.SignatureVerified.
+func Impersonate(ctx context.Context, info TenantInfo) (context.Context, error) {
+	if info.TenantId == "" {
+		return nil, errors.New("impersonating tenant because TenantId is missing")
+	}
+	if info.SystemBaseUri == "" {
+		return nil, errors.New("impersonating tenant because SystemBaseUri is missing")
+	}
+	initiatorSystemBaseUri := info.InitiatorSystemBaseUri
+	if initiatorSystemBaseUri == "" {
+		initiatorSystemBaseUri = info.SystemBaseUri
+	}
+	ctx = SetId(ctx, info.TenantId)
+	ctx = SetSystemBaseUri(ctx, info.SystemBaseUri)
+	ctx = SetInitiatorSystemBaseUri(ctx, initiatorSystemBaseUri)
+	ctx = context.WithValue(ctx, signatureVerifiedCtxKey, false)
+	return context.WithValue(ctx, impersonatedCtxKey, true), nil
+}
+
+// IsImpersonated reports whether the tenant information on the context has been set by Impersonate.
+func IsImpersonated(ctx context.Context) bool {
+	impersonated, _ := ctx.Value(impersonatedCtxKey).(bool)
+	return impersonated
+}
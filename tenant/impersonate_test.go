@@ -0,0 +1,48 @@
+package tenant_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestImpersonate_ReplacesTenantInformationAndMarksContext(t *testing.T) {
+	ctx := tenant.SetId(context.Background(), "a12be5")
+	ctx = tenant.SetSystemBaseUri(ctx, "https://sample.example.com")
+	ctx = tenant.SetInitiatorSystemBaseUri(ctx, "https://initiator.example.com")
+
+	ctx, err := tenant.Impersonate(ctx, tenant.TenantInfo{TenantId: "b34cd6", SystemBaseUri: "https://other.example.com", SignatureVerified: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := tenant.TenantInfo{TenantId: "b34cd6", SystemBaseUri: "https://other.example.com", InitiatorSystemBaseUri: "https://other.example.com"}
+	if got := tenant.InfoFromCtx(ctx); got != want {
+		t.Errorf("got wrong tenant info: got %+v want %+v", got, want)
+	}
+	if !tenant.IsImpersonated(ctx) {
+		t.Error("context should have been marked as impersonated")
+	}
+}
+
+func TestContextWithoutImpersonation_IsImpersonated_ReturnsFalse(t *testing.T) {
+	ctx := tenant.SetId(context.Background(), "a12be5")
+
+	if tenant.IsImpersonated(ctx) {
+		t.Error("context should not have been marked as impersonated")
+	}
+}
+
+func TestIncompleteTenantInfo_Impersonate_ReturnsError(t *testing.T) {
+	for name, info := range map[string]tenant.TenantInfo{
+		"missing tenant id": {SystemBaseUri: "https://other.example.com"},
+		"missing base uri":  {TenantId: "b34cd6"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := tenant.Impersonate(context.Background(), info); err == nil {
+				t.Error("expected an error for incomplete tenant info")
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// WithAllowedInitiators rejects requests whose initiatorSystemBaseUri doesn't have one of the given hosts with
+// status code 403 ("initiator not allowed"). Hosts are compared like in WithAllowedHosts. Requests without
+// initiatorSystemBaseUri are accepted unless WithRequireInitiator is used.
+//
+// The initiatorSystemBaseUri is checked as it is stored on the context, i.e. after the fallback of
+// WithInitiatorFallbackToSystemBaseUri has been applied. Use WithInitiatorFallbackToSystemBaseUri(false)
+// to check only initiators which have actually been forwarded.
+func WithAllowedInitiators(hosts ...string) Option {
+	return func(v *Verifier) error {
+		if len(hosts) == 0 {
+			return errors.New("at least one initiator must be allowed")
+		}
+		v.allowedInitiators = make(map[string]bool, len(hosts))
+		for _, host := range hosts {
+			v.allowedInitiators[canonicalHost(uriPrefix+host)] = true
+		}
+		return nil
+	}
+}
+
+// WithRequireInitiator rejects requests without initiatorSystemBaseUri with status code 403.
+func WithRequireInitiator() Option {
+	return func(v *Verifier) error {
+		v.requireInitiator = true
+		return nil
+	}
+}
+
+// checkInitiator rejects the initiatorSystemBaseUri according to WithAllowedInitiators and WithRequireInitiator.
+func (v *Verifier) checkInitiator(initiatorSystemBaseUri string) *verificationError {
+	if initiatorSystemBaseUri == "" {
+		if v.requireInitiator {
+			return &verificationError{http.StatusForbidden, "initiator missing", nil}
+		}
+		return nil
+	}
+	if v.allowedInitiators == nil || v.allowedInitiators[canonicalHost(initiatorSystemBaseUri)] {
+		return nil
+	}
+	return &verificationError{http.StatusForbidden, fmt.Sprintf("initiator not allowed: host of InitiatorSystemBaseUri '%v' is not allowed", initiatorSystemBaseUri), nil}
+}
+
+const initiatorSignatureHeader = "x-dv-initiator-sig"
+
+// WithInitiatorSignature additionally verifies the signature of the initiator in the header x-dv-initiator-sig,
+// e.g. for a request from app A which is proxied by app B to this app. The header signature x-dv-sig-1 is
+// created by B whereas x-dv-initiator-sig is created by A and proves that the initiator has actually sent the
+// request. It is the signature of SignedMessage(initiatorSystemBaseUri, tenantId, "") where
+// initiatorSystemBaseUri is the uri "https://<host>" derived from the forwarded headers. It is verified with the
+// same keys as the header signature.
+//
+// Signed requests with forwarded headers are rejected with status code 403 if the initiator signature is missing
+// or invalid. Requests without forwarded headers don't have an initiator which could be verified so they are
+// accepted without initiator signature. SignRequest with this option sets the initiator signature as well.
+func WithInitiatorSignature() Option {
+	return func(v *Verifier) error {
+		v.initiatorSignature = true
+		return nil
+	}
+}
+
+// initiatorSignedMessage returns the message which is signed by the initiator or an empty string if the request
+// doesn't contain an initiator.
+func (v *Verifier) initiatorSignedMessage(req *http.Request) string {
+	initiatorSystemBaseUri := v.getForwardedInitiatorSystemBaseUri(req)
+	if initiatorSystemBaseUri == "" {
+		return ""
+	}
+	return SignedMessage(initiatorSystemBaseUri, v.signedHeaderValue(req.Header, tenantIdHeader), "")
+}
+
+// verifyInitiatorSignature verifies the signature of the initiator according to WithInitiatorSignature.
+func (v *Verifier) verifyInitiatorSignature(req *http.Request, scheme SignatureScheme) *verificationError {
+	message := v.initiatorSignedMessage(req)
+	if message == "" {
+		return nil
+	}
+	value := headerValue(req.Header, initiatorSignatureHeader)
+	if value == "" {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("initiator signature header '%v' is missing", initiatorSignatureHeader), ErrMissingSignature}
+	}
+	signature, err := v.decodeHeaderSignature(value)
+	if err != nil {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("decoding initiator signature '%v' because: %v", value, err), ErrMalformedSignature}
+	}
+	if !scheme.Verify([]byte(message), signature) {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("initiator signature is not valid for InitiatorSystemBaseUri '%v'", v.getForwardedInitiatorSystemBaseUri(req)), ErrInvalidSignature}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package tenant_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestInitiators_AllowedInitiators(t *testing.T) {
+	testCases := []struct {
+		name      string
+		forwarded string
+		options   []tenant.Option
+		want      int
+	}{
+		{"allowed initiator", "host=Initiator.example.com", nil, http.StatusOK},
+		{"allowed initiator with default port", "host=initiator.example.com:443", nil, http.StatusOK},
+		{"disallowed initiator", "host=evil.example.com", nil, http.StatusForbidden},
+		{"absent initiator", "", []tenant.Option{tenant.WithInitiatorFallbackToSystemBaseUri(false)}, http.StatusOK},
+		{"absent initiator and required initiator", "", []tenant.Option{tenant.WithInitiatorFallbackToSystemBaseUri(false), tenant.WithRequireInitiator()}, http.StatusForbidden},
+		{"allowed initiator and required initiator", "host=initiator.example.com", []tenant.Option{tenant.WithRequireInitiator()}, http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := signedRequest(t, "https://sample.example.com", "a12be5")
+			if tc.forwarded != "" {
+				req.Header.Set(forwardedHeader, tc.forwarded)
+			}
+			logSpy := loggerSpy{}
+			handlerSpy := handlerSpy{}
+			responseSpy := responseSpy{httptest.NewRecorder()}
+			options := append([]tenant.Option{tenant.WithAllowedInitiators("initiator.example.com")}, tc.options...)
+
+			tenant.AddToCtx("", signatureKey, logSpy.logError, options...)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+			if err := responseSpy.assertStatusCodeIs(tc.want); err != nil {
+				t.Error(err)
+			}
+			if tc.want == http.StatusForbidden && handlerSpy.hasBeenCalled {
+				t.Error("inner handler should not have been called")
+			}
+		})
+	}
+}
+
+func TestDisallowedInitiator_AllowedInitiators_LogsInitiatorNotAllowed(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(xForwardedHostHeader, "evil.example.com")
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithAllowedInitiators("initiator.example.com"))(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := logSpy.assertLogContains("initiator not allowed"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNoHosts_AllowedInitiators_ReturnsError(t *testing.T) {
+	if _, err := tenant.NewVerifier(tenant.WithSignatureSecretKeys(signatureKey), tenant.WithAllowedInitiators()); err == nil {
+		t.Error("expected an error for an empty list of initiators")
+	}
+}
+
+const initiatorSignatureHeader = "x-dv-initiator-sig"
+
+func TestNestedSignatures_InitiatorSignature(t *testing.T) {
+	testCases := []struct {
+		name   string
+		tamper func(req *http.Request)
+		want   int
+	}{
+		{"valid signatures", func(req *http.Request) {}, http.StatusOK},
+		{"tampered initiator", func(req *http.Request) { req.Header.Set(forwardedHeader, "host=evil.example.com") }, http.StatusForbidden},
+		{"tampered initiator signature", func(req *http.Request) {
+			req.Header.Set(initiatorSignatureHeader, base64Signature("https://initiator.example.coma12be5", rotatedSignatureKey))
+		}, http.StatusForbidden},
+		{"missing initiator signature", func(req *http.Request) { req.Header.Del(initiatorSignatureHeader) }, http.StatusForbidden},
+		{"tampered tenant id", func(req *http.Request) { req.Header.Set(tenantIdHeader, "b34cd6") }, http.StatusForbidden},
+		{"tampered header signature", func(req *http.Request) {
+			req.Header.Set(signatureHeader, base64Signature("https://sample.example.coma12be5", rotatedSignatureKey))
+		}, http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := signedRequest(t, "https://sample.example.com", "a12be5")
+			req.Header.Set(forwardedHeader, "host=initiator.example.com")
+			req.Header.Set(initiatorSignatureHeader, base64Signature("https://initiator.example.coma12be5", signatureKey))
+			tc.tamper(req)
+			handlerSpy := handlerSpy{}
+			responseSpy := responseSpy{httptest.NewRecorder()}
+
+			tenant.AddToCtx("", signatureKey, nil, tenant.WithInitiatorSignature())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+			if err := responseSpy.assertStatusCodeIs(tc.want); err != nil {
+				t.Error(err)
+			}
+			if tc.want == http.StatusOK {
+				if err := handlerSpy.assertInitiatorSystemBaseUriIs("https://initiator.example.com"); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
+
+func TestNoInitiator_InitiatorSignature_Returns200(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithInitiatorSignature())(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSignRequest_InitiatorSignature_SetsBothSignatures(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(forwardedHeader, "host=initiator.example.com")
+
+	if err := tenant.SignRequest(req, signatureKey, tenant.WithInitiatorSignature()); err != nil {
+		t.Fatal(err)
+	}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithInitiatorSignature())(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if want := base64Signature("https://initiator.example.coma12be5", signatureKey); req.Header.Get(initiatorSignatureHeader) != want {
+		t.Errorf("got wrong initiator signature: got %v want %v", req.Header.Get(initiatorSignatureHeader), want)
+	}
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+}
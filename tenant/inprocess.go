@@ -0,0 +1,56 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ValidateInProcess verifies the tenant information of a call which doesn't use HTTP, e.g. a call between
+// modules of the same process, whose signature is transmitted separately. The signature is verified like
+// the signature of the tenant headers for the message SignedMessage(info.SystemBaseUri, info.TenantId, "").
+// If WithSignedInitiator is used the InitiatorSystemBaseUri of info is appended to the message.
+//
+// The checks which only depend on the tenant information are applied as well, i.e. WithAllowedHosts,
+// WithUUIDTenantId, WithAllowedInitiators and WithTenantActiveCheck. Options which sign parts of an HTTP
+// request, e.g. WithSignMethodAndPath or WithTimestampInMessage, are ignored because there is no request.
+//
+// The returned error wraps the same sentinel errors as the errors of ValidateRequest.
+func (v *Verifier) ValidateInProcess(info TenantInfo, signatureBase64 string) error {
+	if vErr := v.validateInProcess(info, signatureBase64); vErr != nil {
+		return vErr
+	}
+	return nil
+}
+
+func (v *Verifier) validateInProcess(info TenantInfo, signatureBase64 string) *verificationError {
+	ctx := context.Background()
+	if vErr := v.checkAllowedHost(info.SystemBaseUri); vErr != nil {
+		return vErr
+	}
+	if vErr := v.checkUUIDTenantId(info.TenantId); vErr != nil {
+		return vErr
+	}
+	if vErr := v.checkInitiator(info.InitiatorSystemBaseUri); vErr != nil {
+		return vErr
+	}
+	if signatureBase64 == "" {
+		return &verificationError{http.StatusForbidden, "in-process signature is missing", ErrMissingSignature}
+	}
+	scheme, vErr := v.signatureScheme(ctx, info.TenantId)
+	if vErr != nil {
+		return vErr
+	}
+	signature, err := v.decodeHeaderSignature(signatureBase64)
+	if err != nil {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("decoding in-process signature '%v' because: %v", signatureBase64, err), ErrMalformedSignature}
+	}
+	initiatorSystemBaseUri := ""
+	if v.signedInitiator {
+		initiatorSystemBaseUri = info.InitiatorSystemBaseUri
+	}
+	if !scheme.Verify([]byte(SignedMessage(info.SystemBaseUri, info.TenantId, initiatorSystemBaseUri)), signature) {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("in-process signature is not valid for SystemBaseUri '%v' and TenantId '%v'", info.SystemBaseUri, v.loggedTenantId(info.TenantId)), ErrInvalidSignature}
+	}
+	return v.checkTenantActive(ctx, info.TenantId)
+}
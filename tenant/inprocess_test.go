@@ -0,0 +1,48 @@
+package tenant_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestInProcessCalls_ValidateInProcess(t *testing.T) {
+	info := tenant.TenantInfo{TenantId: "a12be5", SystemBaseUri: "https://sample.example.com"}
+	signature := base64Signature("https://sample.example.coma12be5", signatureKey)
+	testCases := []struct {
+		name      string
+		info      tenant.TenantInfo
+		signature string
+		want      error
+	}{
+		{"valid signature", info, signature, nil},
+		{"tampered tenant id", tenant.TenantInfo{TenantId: "b34cd6", SystemBaseUri: "https://sample.example.com"}, signature, tenant.ErrInvalidSignature},
+		{"tampered systemBaseUri", tenant.TenantInfo{TenantId: "a12be5", SystemBaseUri: "https://evil.example.com"}, signature, tenant.ErrInvalidSignature},
+		{"signature of other key", info, base64Signature("https://sample.example.coma12be5", rotatedSignatureKey), tenant.ErrInvalidSignature},
+		{"malformed signature", info, "not base64!", tenant.ErrMalformedSignature},
+		{"missing signature", info, "", tenant.ErrMissingSignature},
+	}
+	v := tenant.MustNewVerifier(tenant.WithSignatureSecretKeys(signatureKey))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := v.ValidateInProcess(tc.info, tc.signature)
+
+			if tc.want == nil && err != nil {
+				t.Errorf("expected in-process call to be valid but got %v", err)
+			}
+			if tc.want != nil && !errors.Is(err, tc.want) {
+				t.Errorf("got wrong error: got %v want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestDisallowedHost_ValidateInProcess_ReturnsError(t *testing.T) {
+	v := tenant.MustNewVerifier(tenant.WithSignatureSecretKeys(signatureKey), tenant.WithAllowedHosts("other.example.com"))
+	info := tenant.TenantInfo{TenantId: "a12be5", SystemBaseUri: "https://sample.example.com"}
+
+	if err := v.ValidateInProcess(info, base64Signature("https://sample.example.coma12be5", signatureKey)); err == nil {
+		t.Error("expected an error for a disallowed host")
+	}
+}
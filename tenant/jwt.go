@@ -0,0 +1,157 @@
+package tenant
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// JWTKeyFunc returns the key which is used to verify a JWT with the given algorithm and key id.
+// The key id is empty if the JWT header doesn't contain the kid parameter.
+//
+// For the algorithm HS256 the key must be a []byte and for EdDSA an ed25519.PublicKey.
+type JWTKeyFunc func(alg, kid string) (interface{}, error)
+
+// WithJWTSource reads the tenant information from a JWT in the Authorization header
+// (Authorization: Bearer <jwt>) instead of the x-dv-* headers.
+//
+// The claims baseUri, tenantId and initiator are mapped to the systemBaseUri, tenantId and
+// initiatorSystemBaseUri. The claim tenantId is required. If the claim baseUri is missing the
+// defaultSystemBaseUri is used and if the claim initiator is missing the systemBaseUri is used.
+// The registered claims exp and nbf are validated against the clock configured by WithClock.
+// WithSignedExpiryHeader, WithTimestampInMessage and WithNonceStore use the signed claims exp,
+// iat and jti of the JWT instead of their headers.
+// Supported algorithms are HS256 and EdDSA.
+//
+// Requests with an invalid or expired JWT are rejected with status code 401.
+// Requests whose Authorization header doesn't contain a JWT, e.g. because it contains an
+// AuthSessionId, are verified using the x-dv-* headers as usual.
+func WithJWTSource(keyFunc JWTKeyFunc) Option {
+	return func(v *Verifier) error {
+		v.jwtKeyFunc = keyFunc
+		return nil
+	}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	BaseUri   string `json:"baseUri"`
+	TenantId  string `json:"tenantId"`
+	Initiator string `json:"initiator"`
+	Exp       *int64 `json:"exp"`
+	Nbf       *int64 `json:"nbf"`
+	Iat       *int64 `json:"iat"`
+	Jti       string `json:"jti"`
+}
+
+// bearerJWT returns the JWT contained in the Authorization header if there is one.
+func bearerJWT(req *http.Request) (string, bool) {
+	const prefix = "bearer "
+	authorization := headerValue(req.Header, "authorization")
+	if len(authorization) <= len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(authorization[len(prefix):])
+	return token, strings.Count(token, ".") == 2
+}
+
+func (v *Verifier) validateJWT(token string) (TenantInfo, sourceClaims, *verificationError) {
+	claims, err := v.parseJWT(token)
+	if err != nil {
+		return TenantInfo{}, sourceClaims{}, &verificationError{http.StatusUnauthorized, fmt.Sprintf("validating JWT because: %v", err), nil}
+	}
+	if claims.TenantId == "" {
+		return TenantInfo{}, sourceClaims{}, &verificationError{http.StatusUnauthorized, "validating JWT because: claim tenantId is missing", nil}
+	}
+	systemBaseUri := claims.BaseUri
+	if systemBaseUri == "" {
+		systemBaseUri = v.defaultSystemBaseUri
+	}
+	initiatorSystemBaseUri := claims.Initiator
+	if initiatorSystemBaseUri == "" && v.initiatorFallback {
+		initiatorSystemBaseUri = systemBaseUri
+	}
+	signed := sourceClaims{source: sourceJWT, systemBaseUri: claims.BaseUri, tenantId: claims.TenantId, nonce: claims.Jti}
+	if claims.Exp != nil {
+		signed.expiry = time.Unix(*claims.Exp, 0)
+	}
+	if claims.Iat != nil {
+		signed.issuedAt = time.Unix(*claims.Iat, 0)
+	}
+	return TenantInfo{
+		TenantId:               claims.TenantId,
+		SystemBaseUri:          normalizeBaseUri(systemBaseUri),
+		InitiatorSystemBaseUri: normalizeBaseUri(initiatorSystemBaseUri),
+		SignatureVerified:      true,
+	}, signed, nil
+}
+
+func (v *Verifier) parseJWT(token string) (jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return jwtClaims{}, fmt.Errorf("decoding header: %v", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return jwtClaims{}, fmt.Errorf("decoding signature: %v", err)
+	}
+	key, err := v.jwtKeyFunc(header.Alg, header.Kid)
+	if err != nil {
+		return jwtClaims{}, fmt.Errorf("getting key for kid '%v': %v", header.Kid, err)
+	}
+	signingInput := []byte(parts[0] + "." + parts[1])
+	if !jwtSignatureIsValid(header.Alg, key, signingInput, signature) {
+		return jwtClaims{}, fmt.Errorf("signature is not valid for algorithm '%v'", header.Alg)
+	}
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return jwtClaims{}, fmt.Errorf("decoding claims: %v", err)
+	}
+	now := v.now()
+	if claims.Exp != nil && !now.Before(time.Unix(*claims.Exp, 0)) {
+		return jwtClaims{}, fmt.Errorf("token expired at %v", time.Unix(*claims.Exp, 0).UTC())
+	}
+	if claims.Nbf != nil && now.Before(time.Unix(*claims.Nbf, 0)) {
+		return jwtClaims{}, fmt.Errorf("token not valid before %v", time.Unix(*claims.Nbf, 0).UTC())
+	}
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func jwtSignatureIsValid(alg string, key interface{}, signingInput, signature []byte) bool {
+	switch alg {
+	case "HS256":
+		k, ok := key.([]byte)
+		if !ok {
+			return false
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write(signingInput)
+		return hmac.Equal(signature, mac.Sum(nil))
+	case "EdDSA":
+		k, ok := key.(ed25519.PublicKey)
+		if !ok || len(k) != ed25519.PublicKeySize {
+			return false
+		}
+		return ed25519.Verify(k, signingInput, signature)
+	}
+	return false
+}
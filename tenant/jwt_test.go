@@ -0,0 +1,200 @@
+package tenant_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+var jwtKey = []byte("jwt-secret-key-for-tests")
+
+func TestValidJWT_AddsClaimsToContext(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+hs256JWT(t, map[string]interface{}{
+		"baseUri":   "https://sample.example.com",
+		"tenantId":  "a12be5",
+		"initiator": "https://initiator.example.com",
+		"exp":       now.Add(time.Minute).Unix(),
+	}))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithJWTSource(jwtKeyFunc), tenant.WithClock(func() time.Time { return now }))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertBaseUriIs("https://sample.example.com"); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertInitiatorSystemBaseUriIs("https://initiator.example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExpiredJWT_Returns401(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+hs256JWT(t, map[string]interface{}{
+		"baseUri":  "https://sample.example.com",
+		"tenantId": "a12be5",
+		"exp":      now.Add(-time.Minute).Unix(),
+	}))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithJWTSource(jwtKeyFunc), tenant.WithClock(func() time.Time { return now }))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusUnauthorized); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+	if err := logSpy.assertLogContains("expired"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBearerTokenWhichIsNoJWT_UsesHeaders(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set("Authorization", "Bearer someAuthSessionId")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithJWTSource(jwtKeyFunc))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+}
+
+func jwtKeyFunc(alg, kid string) (interface{}, error) {
+	if alg != "HS256" {
+		return nil, errors.New("unexpected algorithm")
+	}
+	return jwtKey, nil
+}
+
+func hs256JWT(t *testing.T, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := base64.StdEncoding.DecodeString(base64Signature(signingInput, jwtKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestValidJWT_AppliesChecksOfOtherOptions(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	clock := tenant.WithClock(func() time.Time { return now })
+	testCases := map[string]struct {
+		claims map[string]interface{}
+		header string
+		option tenant.Option
+		status int
+	}{
+		"disallowed host":        {map[string]interface{}{"baseUri": "https://evil.example.com"}, "", tenant.WithAllowedHosts("sample.example.com"), http.StatusForbidden},
+		"missing body signature": {map[string]interface{}{}, "", tenant.WithBodySignature(), http.StatusForbidden},
+		"missing jti":            {map[string]interface{}{}, "x-dv-nonce", tenant.WithNonceStore(tenant.NewMemoryNonceStore()), http.StatusUnauthorized},
+		"missing exp":            {map[string]interface{}{}, "x-dv-expires", tenant.WithSignedExpiryHeader("x-dv-expires"), http.StatusUnauthorized},
+		"missing iat":            {map[string]interface{}{"iat": nil}, "x-dv-timestamp", tenant.WithTimestampInMessage("x-dv-timestamp", time.RFC3339), http.StatusUnauthorized},
+		"old iat":                {map[string]interface{}{"iat": now.Add(-time.Hour).Unix()}, "x-dv-timestamp", tenant.WithTimestampInMessage("x-dv-timestamp", time.RFC3339), http.StatusForbidden},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/myresource/sub", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims := map[string]interface{}{"baseUri": "https://sample.example.com", "tenantId": "a12be5", "iat": now.Unix()}
+			for name, value := range tc.claims {
+				claims[name] = value
+			}
+			req.Header.Set("Authorization", "Bearer "+hs256JWT(t, claims))
+			if tc.header != "" {
+				// unsigned headers must not substitute the claims of the JWT
+				req.Header.Set(tc.header, strconv.FormatInt(now.Unix(), 10))
+			}
+			handlerSpy := handlerSpy{}
+			responseSpy := responseSpy{httptest.NewRecorder()}
+
+			tenant.AddToCtx("", signatureKey, nil, tenant.WithJWTSource(jwtKeyFunc), clock, tenant.WithTimestampInMessage("x-dv-timestamp", time.RFC3339), tc.option)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+			if err := responseSpy.assertStatusCodeIs(tc.status); err != nil {
+				t.Error(err)
+			}
+			if handlerSpy.hasBeenCalled {
+				t.Error("inner handler should not have been called")
+			}
+		})
+	}
+}
+
+func TestJWTWithSignedClaims_UsesClaimsForExpiryTimestampAndNonce(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	expiry := now.Add(time.Minute)
+	token := hs256JWT(t, map[string]interface{}{
+		"baseUri":  "https://sample.example.com",
+		"tenantId": "a12be5",
+		"exp":      expiry.Unix(),
+		"iat":      now.Unix(),
+		"jti":      "3f9c1a",
+	})
+	var deadline time.Time
+	handler := tenant.AddToCtx("", signatureKey, nil,
+		tenant.WithJWTSource(jwtKeyFunc),
+		tenant.WithClock(func() time.Time { return now }),
+		tenant.WithSignedExpiryHeader("x-dv-expires"),
+		tenant.WithTimestampInMessage("x-dv-timestamp", time.RFC3339),
+		tenant.WithNonceStore(tenant.NewMemoryNonceStore()),
+	)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		deadline, _ = r.Context().Deadline()
+	}))
+
+	for _, status := range []int{http.StatusOK, http.StatusConflict} {
+		req, _ := http.NewRequest("GET", "/myresource/sub", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		responseSpy := responseSpy{httptest.NewRecorder()}
+
+		handler.ServeHTTP(responseSpy, req)
+
+		if err := responseSpy.assertStatusCodeIs(status); err != nil {
+			t.Error(err)
+		}
+	}
+	if !deadline.Equal(expiry) {
+		t.Errorf("got wrong deadline: got %v want %v", deadline, expiry)
+	}
+}
@@ -0,0 +1,46 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// WithKeyIdDerivation verifies the signatures of each request with a key which is derived from the key id
+// sent in the header with the given name, e.g. "x-dv-kid". deriveKey is called with the key id and must return
+// the key derived from the master secret and the key id, or nil if the key id is unknown or invalid.
+// Requests without key id or with a key id for which no key is derived are rejected with status code 403.
+//
+// The derived key is used instead of the configured keys for the signature of the tenant headers and the
+// body signature. The key id itself isn't part of the signed message because a different key id results
+// in a different key anyway.
+func WithKeyIdDerivation(headerName string, deriveKey func(kid string) []byte) Option {
+	return func(v *Verifier) error {
+		if headerName == "" {
+			return errors.New("key id header name must not be empty")
+		}
+		if deriveKey == nil {
+			return errors.New("key derivation must not be nil")
+		}
+		v.keyIdHeader = headerName
+		v.deriveKey = deriveKey
+		return nil
+	}
+}
+
+// requestSignatureScheme returns the SignatureScheme which is used to verify the signatures of req. It derives
+// the key from the key id of req if WithKeyIdDerivation is used.
+func (v *Verifier) requestSignatureScheme(req *http.Request, tenantId string) (SignatureScheme, *verificationError) {
+	if v.deriveKey == nil {
+		return v.signatureScheme(req.Context(), tenantId)
+	}
+	kid := headerValue(req.Header, v.keyIdHeader)
+	if kid == "" {
+		return nil, &verificationError{http.StatusForbidden, fmt.Sprintf("key id header '%v' is missing", v.keyIdHeader), nil}
+	}
+	key := v.deriveKey(kid)
+	if len(key) == 0 {
+		return nil, &verificationError{http.StatusForbidden, fmt.Sprintf("unknown key id '%v'", kid), nil}
+	}
+	return hmacScheme{[][]byte{key}}, nil
+}
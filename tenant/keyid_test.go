@@ -0,0 +1,82 @@
+package tenant_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+const keyIdHeader = "x-dv-kid"
+
+// deriveKeyFromId derives the keys of the key ids "2024-01" and "2024-02" from the masterSecret.
+func deriveKeyFromId(kid string) []byte {
+	if kid != "2024-01" && kid != "2024-02" {
+		return nil
+	}
+	mac := hmac.New(sha256.New, masterSecret)
+	mac.Write([]byte("dvelop-sdk-go tenant signature key " + kid))
+	return mac.Sum(nil)
+}
+
+func TestKeyIds_KeyIdDerivation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		kid     string
+		signKid string
+		want    int
+	}{
+		{"valid key id", "2024-01", "2024-01", http.StatusOK},
+		{"other valid key id", "2024-02", "2024-02", http.StatusOK},
+		{"key id of another key", "2024-02", "2024-01", http.StatusForbidden},
+		{"unknown key id", "1999-01", "2024-01", http.StatusForbidden},
+		{"missing key id", "", "2024-01", http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := signedRequestWithKey(t, "https://sample.example.com", "a12be5", deriveKeyFromId(tc.signKid))
+			if tc.kid != "" {
+				req.Header.Set(keyIdHeader, tc.kid)
+			}
+			handlerSpy := handlerSpy{}
+			responseSpy := responseSpy{httptest.NewRecorder()}
+
+			tenant.AddToCtx("", nil, nil, tenant.WithKeyIdDerivation(keyIdHeader, deriveKeyFromId))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+			if err := responseSpy.assertStatusCodeIs(tc.want); err != nil {
+				t.Error(err)
+			}
+			if tc.want == http.StatusOK {
+				if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
+
+func TestUnknownKeyId_KeyIdDerivation_LogsKeyId(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(keyIdHeader, "1999-01")
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithKeyIdDerivation(keyIdHeader, deriveKeyFromId))(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := logSpy.assertLogContains("unknown key id '1999-01'"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOnlyDerivedKeys_Ready_ReturnsNil(t *testing.T) {
+	v, err := tenant.NewVerifier(tenant.WithKeyIdDerivation(keyIdHeader, deriveKeyFromId))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v.Ready(); err != nil {
+		t.Errorf("expected verifier to be ready but got %v", err)
+	}
+}
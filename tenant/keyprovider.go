@@ -0,0 +1,437 @@
+package tenant
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// KeyProvider is an interface representing the ability to provide the signature secret keys
+// which are used to verify HMAC signatures
+type KeyProvider interface {
+	// Keys returns the keys which are currently valid for the tenant. A signature is accepted if it
+	// is valid for any of the keys. The tenantId is the id claimed by the request which hasn't been
+	// verified yet. It is empty if the request doesn't contain a tenant id.
+	Keys(ctx context.Context, tenantId string) ([][]byte, error)
+}
+
+// WithKeyProvider verifies HMAC signatures with the keys returned by the given KeyProvider instead of
+// the signatureSecretKey. It replaces a SignatureScheme which has been configured before.
+func WithKeyProvider(provider KeyProvider) Option {
+	return func(v *Verifier) error {
+		v.keyProvider = provider
+		v.scheme = nil
+		return nil
+	}
+}
+
+// WithMaxKeysPerRequest limits the number of keys which are tried to verify the signature of a single request.
+// If the KeyProvider returns more keys the request is rejected with status code 500 without trying any key.
+// This bounds the work per request if the KeyProvider is misconfigured. Defaults to no limit.
+func WithMaxKeysPerRequest(n int) Option {
+	return func(v *Verifier) error {
+		if n <= 0 {
+			return fmt.Errorf("max keys per request must be positive but is %v", n)
+		}
+		v.maxKeysPerRequest = n
+		return nil
+	}
+}
+
+// WithKeyRotation verifies HMAC signatures with the current key and additionally with the previous key until
+// overlapUntil. Afterwards only the current key is accepted. So the time in which the previous key is accepted
+// after a rotation is bounded. The time is taken from the clock configured by WithClock.
+// It replaces a KeyProvider or SignatureScheme which has been configured before.
+func WithKeyRotation(current []byte, previous []byte, overlapUntil time.Time) Option {
+	return func(v *Verifier) error {
+		if len(current) == 0 {
+			return errors.New("current signature secret key must not be empty")
+		}
+		v.keyProvider = rotationKeyProvider{
+			current:      current,
+			previous:     previous,
+			overlapUntil: overlapUntil,
+			// the clock may be configured after this option
+			now: func() time.Time { return v.now() },
+		}
+		v.scheme = nil
+		return nil
+	}
+}
+
+type rotationKeyProvider struct {
+	current      []byte
+	previous     []byte
+	overlapUntil time.Time
+	now          func() time.Time
+}
+
+func (p rotationKeyProvider) Keys(ctx context.Context, tenantId string) ([][]byte, error) {
+	if len(p.previous) > 0 && p.now().Before(p.overlapUntil) {
+		return [][]byte{p.current, p.previous}, nil
+	}
+	return [][]byte{p.current}, nil
+}
+
+type staticKeyProvider [][]byte
+
+func (p staticKeyProvider) Keys(ctx context.Context, tenantId string) ([][]byte, error) {
+	return p, nil
+}
+
+// signatureScheme returns the SignatureScheme which is used to verify the signatures of a request.
+func (v *Verifier) signatureScheme(ctx context.Context, tenantId string) (SignatureScheme, *verificationError) {
+	if v.scheme != nil {
+		return v.scheme, nil
+	}
+	if v.keyProvider == nil {
+		return nil, &verificationError{v.missingKeyStatus, fmt.Sprintf("validating signature for headers '%v' and '%v' because secret signature key has not been configured", systemBaseUriHeader, tenantIdHeader), ErrMissingSignatureKey}
+	}
+	keys, err := v.lookupKeys(ctx, tenantId)
+	if err != nil {
+		return nil, &verificationError{http.StatusInternalServerError, fmt.Sprintf("getting secret signature keys because: %v", err), nil}
+	}
+	if _, static := v.keyProvider.(staticKeyProvider); !static && v.keyTransform != nil {
+		// static keys have already been transformed by newVerifier
+		keys = transformKeys(v.keyTransform, keys)
+	}
+	if len(keys) == 0 {
+		return nil, &verificationError{v.missingKeyStatus, fmt.Sprintf("validating signature for headers '%v' and '%v' because secret signature key has not been configured", systemBaseUriHeader, tenantIdHeader), ErrMissingSignatureKey}
+	}
+	if v.maxKeysPerRequest > 0 && len(keys) > v.maxKeysPerRequest {
+		return nil, &verificationError{http.StatusInternalServerError, fmt.Sprintf("validating signature because too many keys: got %v secret signature keys but at most %v are allowed", len(keys), v.maxKeysPerRequest), nil}
+	}
+	return hmacScheme{keys}, nil
+}
+
+// WithKeyTransform applies transform to each HMAC key before it is used, e.g. to prepend a deployment specific
+// salt to the key. Static keys, i.e. the keys passed to AddToCtx or WithSignatureSecretKeys, are transformed once
+// when the middleware is created whereas the keys of a KeyProvider are transformed for every request.
+// SignRequest with this option transforms its key as well. The keys passed to transform must not be modified.
+func WithKeyTransform(transform func(key []byte) []byte) Option {
+	return func(v *Verifier) error {
+		if transform == nil {
+			return errors.New("key transform must not be nil")
+		}
+		v.keyTransform = transform
+		return nil
+	}
+}
+
+// transformKeys returns the keys transformed by transform.
+func transformKeys(transform func(key []byte) []byte, keys [][]byte) [][]byte {
+	transformed := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		transformed = append(transformed, transform(key))
+	}
+	return transformed
+}
+
+// WithMatchedKeyReporter calls report with the index of the key which has verified the signature of the tenant
+// headers, e.g. to count how often callers still sign with an old key during a key rotation. The index refers to
+// the keys passed to AddToCtx or WithSignatureSecretKeys or returned by the KeyProvider, so 0 is the current key.
+// Signatures which are verified by a SignatureScheme other than HMACScheme, e.g. Ed25519Scheme, or accepted from the
+// cache of WithVerificationCache are not reported.
+func WithMatchedKeyReporter(report func(ctx context.Context, index int)) Option {
+	return func(v *Verifier) error {
+		if report == nil {
+			return errors.New("matched key reporter must not be nil")
+		}
+		v.matchedKeyReporter = report
+		return nil
+	}
+}
+
+// verifyAndReportKey verifies the signature with scheme and reports the index of the matching key according
+// to WithMatchedKeyReporter.
+func (v *Verifier) verifyAndReportKey(ctx context.Context, scheme SignatureScheme, message, signature []byte) bool {
+	hmacScheme, ok := scheme.(hmacScheme)
+	if !ok || v.matchedKeyReporter == nil {
+		return scheme.Verify(message, signature)
+	}
+	index := matchingKeyIndex(message, signature, hmacScheme.keys)
+	if index < 0 {
+		return false
+	}
+	v.matchedKeyReporter(ctx, index)
+	return true
+}
+
+// WithKeyLookupRetry calls the KeyProvider up to attempts times if it returns an error, e.g. because a secret
+// store is temporarily unavailable. It waits for backoff between the attempts. The retries are stopped if the
+// request is canceled or if its deadline would pass before the next attempt. The error of the last attempt is
+// logged and the request is rejected with status code 500 as without retries.
+func WithKeyLookupRetry(attempts int, backoff time.Duration) Option {
+	return func(v *Verifier) error {
+		if attempts < 1 {
+			return fmt.Errorf("key lookup attempts must be at least 1 but are %v", attempts)
+		}
+		if backoff < 0 {
+			return fmt.Errorf("key lookup backoff must not be negative but is %v", backoff)
+		}
+		v.keyLookupAttempts = attempts
+		v.keyLookupBackoff = backoff
+		return nil
+	}
+}
+
+// lookupKeys returns the keys of the KeyProvider and retries according to WithKeyLookupRetry.
+func (v *Verifier) lookupKeys(ctx context.Context, tenantId string) ([][]byte, error) {
+	keys, err := v.keyProvider.Keys(ctx, tenantId)
+	for attempt := 1; err != nil && attempt < v.keyLookupAttempts; attempt++ {
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(v.keyLookupBackoff).After(deadline) {
+			break
+		}
+		timer := time.NewTimer(v.keyLookupBackoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		keys, err = v.keyProvider.Keys(ctx, tenantId)
+	}
+	return keys, err
+}
+
+// RemoteKeyProvider is a KeyProvider which fetches the keys from a well-known url and caches them.
+//
+// The url must return a JSON array of base64 encoded keys, e.g. ["U2VjcmV0","T3RoZXJTZWNyZXQ="].
+// The keys are cached for the configured ttl. Afterwards they are revalidated using the ETag
+// returned by the server. If fetching the keys fails the last keys which have been fetched
+// successfully are used and a warning is logged. If no keys have been fetched yet, further
+// attempts are delayed by the retry backoff.
+//
+// Only one fetch is in flight at a time. While the cached keys are being revalidated concurrent
+// calls return the cached keys, otherwise they wait for the running fetch. The fetch doesn't use the
+// context of the caller but is bounded by the fetch timeout, so a canceled request neither aborts
+// the fetch for the other callers nor is it taken as a failure of the url.
+type RemoteKeyProvider struct {
+	url          string
+	ttl          time.Duration
+	retryBackoff time.Duration
+	fetchTimeout time.Duration
+	httpClient   *http.Client
+	logger       func(ctx context.Context, message string)
+	now          func() time.Time
+
+	mu         sync.Mutex // protects the following fields
+	keys       [][]byte
+	etag       string
+	expires    time.Time
+	retryAfter time.Time
+	lastErr    error
+	fetching   chan struct{}
+}
+
+// RemoteKeyProviderOption configures a RemoteKeyProvider.
+type RemoteKeyProviderOption func(*RemoteKeyProvider)
+
+// defaultRemoteKeyRetryBackoff is the time a RemoteKeyProvider waits before fetching the keys again if
+// no keys could be fetched so far.
+const defaultRemoteKeyRetryBackoff = 5 * time.Second
+
+// defaultRemoteKeyFetchTimeout is the time after which a RemoteKeyProvider aborts fetching the keys.
+const defaultRemoteKeyFetchTimeout = 10 * time.Second
+
+// maxRemoteKeysSize is the maximum size of the response of the url of a RemoteKeyProvider which is read.
+const maxRemoteKeysSize = 1 << 20
+
+// WithRemoteKeyClock uses now instead of time.Now to determine whether the cached keys have expired.
+func WithRemoteKeyClock(now func() time.Time) RemoteKeyProviderOption {
+	return func(p *RemoteKeyProvider) {
+		p.now = now
+	}
+}
+
+// WithRemoteKeyRetryBackoff sets the time the RemoteKeyProvider waits before fetching the keys again if
+// no keys could be fetched so far. Calls in between fail with the last error. The default is 5 seconds.
+func WithRemoteKeyRetryBackoff(backoff time.Duration) RemoteKeyProviderOption {
+	return func(p *RemoteKeyProvider) {
+		p.retryBackoff = backoff
+	}
+}
+
+// WithRemoteKeyFetchTimeout sets the time after which the RemoteKeyProvider aborts fetching the keys.
+// The default is 10 seconds.
+func WithRemoteKeyFetchTimeout(timeout time.Duration) RemoteKeyProviderOption {
+	return func(p *RemoteKeyProvider) {
+		p.fetchTimeout = timeout
+	}
+}
+
+// NewRemoteKeyProvider creates a new RemoteKeyProvider which fetches the keys from url using http.DefaultClient.
+// The logger is used to log warnings if the keys could not be fetched. It may be nil.
+func NewRemoteKeyProvider(url string, ttl time.Duration, logger func(ctx context.Context, message string), opts ...RemoteKeyProviderOption) *RemoteKeyProvider {
+	if logger == nil {
+		logger = func(ctx context.Context, message string) {}
+	}
+	p := &RemoteKeyProvider{
+		url:          url,
+		ttl:          ttl,
+		retryBackoff: defaultRemoteKeyRetryBackoff,
+		fetchTimeout: defaultRemoteKeyFetchTimeout,
+		httpClient:   http.DefaultClient,
+		logger:       logger,
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Keys returns the cached keys and fetches them from the url if the cache has expired.
+func (p *RemoteKeyProvider) Keys(ctx context.Context, tenantId string) ([][]byte, error) {
+	p.mu.Lock()
+	for {
+		now := p.now()
+		if p.keys != nil && now.Before(p.expires) {
+			keys := p.keys
+			p.mu.Unlock()
+			return keys, nil
+		}
+		if p.keys == nil && p.lastErr != nil && now.Before(p.retryAfter) {
+			err := p.lastErr
+			p.mu.Unlock()
+			return nil, err
+		}
+		if p.fetching == nil {
+			break
+		}
+		if p.keys != nil {
+			keys := p.keys
+			p.mu.Unlock()
+			return keys, nil
+		}
+		fetching := p.fetching
+		p.mu.Unlock()
+		select {
+		case <-fetching:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		p.mu.Lock()
+	}
+	fetching := make(chan struct{})
+	p.fetching = fetching
+	etag := ""
+	if p.keys != nil {
+		etag = p.etag
+	}
+	p.mu.Unlock()
+
+	go p.refresh(fetching, etag)
+	select {
+	case <-fetching:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys == nil {
+		return nil, p.lastErr
+	}
+	return p.keys, nil
+}
+
+// refresh fetches the keys, updates the cache and closes fetching when it is done.
+func (p *RemoteKeyProvider) refresh(fetching chan struct{}, etag string) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.fetchTimeout)
+	defer cancel()
+	keys, etag, err := p.fetch(ctx, etag)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fetching = nil
+	close(fetching)
+	now := p.now()
+	if err != nil {
+		if p.keys == nil {
+			p.lastErr = err
+			p.retryAfter = now.Add(p.retryBackoff)
+			return
+		}
+		p.logger(ctx, fmt.Sprintf("WARNING: using last known signature keys because fetching keys from '%v' failed: %v", p.url, err))
+	} else if keys != nil {
+		p.keys = keys
+		p.etag = etag
+	}
+	p.lastErr = nil
+	p.expires = now.Add(p.ttl)
+}
+
+// fetch requests the keys from the url. It returns nil keys if the server responded that the keys
+// identified by etag haven't been modified.
+func (p *RemoteKeyProvider) fetch(ctx context.Context, etag string) ([][]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req = req.WithContext(ctx)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if etag == "" {
+			return nil, "", errors.New("unexpected status code 304 for request without ETag")
+		}
+		return nil, etag, nil
+	case http.StatusOK:
+		var base64Keys []string
+		if err := json.NewDecoder(io.LimitReader(resp.Body, maxRemoteKeysSize)).Decode(&base64Keys); err != nil {
+			return nil, "", fmt.Errorf("decoding keys as JSON array because: %v", err)
+		}
+		if len(base64Keys) == 0 {
+			return nil, "", errors.New("response doesn't contain any keys")
+		}
+		keys := make([][]byte, 0, len(base64Keys))
+		for i, base64Key := range base64Keys {
+			key, err := base64.StdEncoding.DecodeString(base64Key)
+			if err != nil {
+				return nil, "", fmt.Errorf("decoding key %v as base 64 data because: %v", i, err)
+			}
+			keys = append(keys, key)
+		}
+		return keys, resp.Header.Get("ETag"), nil
+	default:
+		return nil, "", fmt.Errorf("unexpected status code %v", resp.StatusCode)
+	}
+}
+
+// exampleKey is the signature secret key used in the example of the package documentation.
+var exampleKey = []byte("Secret")
+
+// isWeakKey reports whether key is an obvious placeholder, i.e. it consists of a single repeated byte
+// (like all zeros) or is the example value from the documentation.
+func isWeakKey(key []byte) bool {
+	if len(key) == 0 || bytes.Equal(key, exampleKey) {
+		return true
+	}
+	for _, b := range key[1:] {
+		if b != key[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// keyWarmer is implemented by KeyProviders which can cache the keys of tenants in advance, e.g. HKDFKeyProvider.
+type keyWarmer interface {
+	WarmKeys(tenantIds ...string)
+}
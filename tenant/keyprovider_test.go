@@ -0,0 +1,536 @@
+package tenant_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+var rotatedSignatureKey = []byte{17, 219, 144, 209, 189, 1, 178, 73, 139, 47, 21, 236, 142, 56, 71, 245, 43, 188, 163, 52, 239, 102, 94, 153, 255, 159, 199, 149, 163, 145, 161, 24}
+
+func TestRemoteKeyProvider_PicksUpRotatedKeysAfterTtl(t *testing.T) {
+	keyServer := newKeyServerStub(signatureKey)
+	defer keyServer.Close()
+	logSpy := loggerSpy{}
+	const ttl = time.Minute
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	clock := tenant.WithRemoteKeyClock(func() time.Time { return now })
+	addToCtx := tenant.AddToCtx("", nil, logSpy.logError, tenant.WithKeyProvider(tenant.NewRemoteKeyProvider(keyServer.URL, ttl, logSpy.logError, clock)))
+
+	if status := serveWithKey(t, addToCtx, signatureKey); status != http.StatusOK {
+		t.Errorf("got wrong status code for initial key: got %v want %v", status, http.StatusOK)
+	}
+	keyServer.setKeys(rotatedSignatureKey)
+	if status := serveWithKey(t, addToCtx, rotatedSignatureKey); status != http.StatusForbidden {
+		t.Errorf("got wrong status code for rotated key before ttl: got %v want %v", status, http.StatusForbidden)
+	}
+	now = now.Add(ttl)
+	if status := serveWithKey(t, addToCtx, rotatedSignatureKey); status != http.StatusOK {
+		t.Errorf("got wrong status code for rotated key after ttl: got %v want %v", status, http.StatusOK)
+	}
+}
+
+func TestRemoteKeyProvider_RevalidatesWithETag(t *testing.T) {
+	keyServer := newKeyServerStub(signatureKey)
+	defer keyServer.Close()
+	logSpy := loggerSpy{}
+	provider := tenant.NewRemoteKeyProvider(keyServer.URL, 0, logSpy.logError)
+
+	_, _ = provider.Keys(context.Background(), "a12be5")
+	keys, err := provider.Keys(context.Background(), "a12be5")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || string(keys[0]) != string(signatureKey) {
+		t.Errorf("got wrong keys: %v", keys)
+	}
+	if keyServer.notModifiedCount != 1 {
+		t.Errorf("expected keys to be revalidated with ETag")
+	}
+}
+
+func TestRemoteKeyProviderAndFailingServer_UsesLastKnownKeys(t *testing.T) {
+	keyServer := newKeyServerStub(signatureKey)
+	defer keyServer.Close()
+	logSpy := loggerSpy{}
+	provider := tenant.NewRemoteKeyProvider(keyServer.URL, 0, logSpy.logError)
+
+	_, _ = provider.Keys(context.Background(), "a12be5")
+	keyServer.setFailing()
+	keys, err := provider.Keys(context.Background(), "a12be5")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || string(keys[0]) != string(signatureKey) {
+		t.Errorf("got wrong keys: %v", keys)
+	}
+	if err := logSpy.assertLogContains("WARNING"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRemoteKeyProviderAndFailingFirstFetch_BacksOff(t *testing.T) {
+	keyServer := newKeyServerStub(signatureKey)
+	defer keyServer.Close()
+	keyServer.setFailing()
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	provider := tenant.NewRemoteKeyProvider(keyServer.URL, time.Minute, nil,
+		tenant.WithRemoteKeyClock(func() time.Time { return now }), tenant.WithRemoteKeyRetryBackoff(time.Second))
+
+	if _, err := provider.Keys(context.Background(), "a12be5"); err == nil {
+		t.Error("expected error for failing server")
+	}
+	if _, err := provider.Keys(context.Background(), "a12be5"); err == nil {
+		t.Error("expected error during backoff")
+	}
+	if got := keyServer.requests(); got != 1 {
+		t.Errorf("got wrong number of requests during backoff: got %v want %v", got, 1)
+	}
+	keyServer.setHealthy()
+	now = now.Add(time.Second)
+	keys, err := provider.Keys(context.Background(), "a12be5")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || string(keys[0]) != string(signatureKey) {
+		t.Errorf("got wrong keys: %v", keys)
+	}
+}
+
+func TestRemoteKeyProviderAndConcurrentCalls_FetchesOnce(t *testing.T) {
+	keyServer := newKeyServerStub(signatureKey)
+	defer keyServer.Close()
+	provider := tenant.NewRemoteKeyProvider(keyServer.URL, time.Hour, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := provider.Keys(context.Background(), "a12be5"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := keyServer.requests(); got != 1 {
+		t.Errorf("got wrong number of requests: got %v want %v", got, 1)
+	}
+}
+
+func TestRemoteKeyProviderAndCanceledCaller_FetchesKeysForOtherCallers(t *testing.T) {
+	keyServer := newKeyServerStub(signatureKey)
+	defer keyServer.Close()
+	provider := tenant.NewRemoteKeyProvider(keyServer.URL, time.Hour, nil, tenant.WithRemoteKeyRetryBackoff(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _ = provider.Keys(ctx, "a12be5")
+	keys, err := provider.Keys(context.Background(), "a12be5")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || string(keys[0]) != string(signatureKey) {
+		t.Errorf("got wrong keys: %v", keys)
+	}
+	if got := keyServer.requests(); got != 1 {
+		t.Errorf("got wrong number of requests: got %v want %v", got, 1)
+	}
+}
+
+func TestRemoteKeyProviderAndOversizedResponse_ReturnsError(t *testing.T) {
+	keyServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		key := `"` + base64.StdEncoding.EncodeToString(signatureKey) + `"`
+		fmt.Fprint(rw, "["+key)
+		for i := 0; i < 1<<16; i++ {
+			fmt.Fprint(rw, ","+key)
+		}
+		fmt.Fprint(rw, "]")
+	}))
+	defer keyServer.Close()
+	provider := tenant.NewRemoteKeyProvider(keyServer.URL, time.Hour, nil)
+
+	if _, err := provider.Keys(context.Background(), "a12be5"); err == nil {
+		t.Error("expected error for oversized response")
+	}
+}
+
+func serveWithKey(t *testing.T, addToCtx func(http.Handler) http.Handler, key []byte) int {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(tenantIdHeader, "a12be5")
+	req.Header.Set(signatureHeader, base64Signature("a12be5", key))
+	rec := httptest.NewRecorder()
+	addToCtx(&handlerSpy{}).ServeHTTP(rec, req)
+	return rec.Code
+}
+
+type keyServerStub struct {
+	*httptest.Server
+	mu               sync.Mutex
+	keys             [][]byte
+	version          int
+	failing          bool
+	notModifiedCount int
+	requestCount     int
+}
+
+func newKeyServerStub(keys ...[]byte) *keyServerStub {
+	stub := &keyServerStub{keys: keys}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		stub.mu.Lock()
+		defer stub.mu.Unlock()
+		stub.requestCount++
+		if stub.failing {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		etag := fmt.Sprintf(`"%v"`, stub.version)
+		if r.Header.Get("If-None-Match") == etag {
+			stub.notModifiedCount++
+			rw.WriteHeader(http.StatusNotModified)
+			return
+		}
+		rw.Header().Set("ETag", etag)
+		fmt.Fprint(rw, "[")
+		for i, key := range stub.keys {
+			if i > 0 {
+				fmt.Fprint(rw, ",")
+			}
+			fmt.Fprintf(rw, `"%v"`, base64.StdEncoding.EncodeToString(key))
+		}
+		fmt.Fprint(rw, "]")
+	}))
+	return stub
+}
+
+func (stub *keyServerStub) setKeys(keys ...[]byte) {
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	stub.keys = keys
+	stub.version++
+}
+
+func (stub *keyServerStub) setFailing() {
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	stub.failing = true
+}
+
+func (stub *keyServerStub) setHealthy() {
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	stub.failing = false
+}
+
+func (stub *keyServerStub) requests() int {
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	return stub.requestCount
+}
+
+func TestAllZeroSignatureKey_LogsWarning(t *testing.T) {
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", make([]byte, 32), logSpy.logError)
+
+	if err := logSpy.assertLogContains("WARNING"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExampleSignatureKey_LogsWarning(t *testing.T) {
+	logSpy := loggerSpy{}
+	exampleKey, _ := base64.StdEncoding.DecodeString("U2VjcmV0")
+
+	tenant.AddToCtx("", exampleKey, logSpy.logError)
+
+	if err := logSpy.assertLogContains("WARNING"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRandomSignatureKey_DoesntLogWarning(t *testing.T) {
+	logSpy := loggerSpy{}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+
+	tenant.AddToCtx("", key, logSpy.logError)
+
+	if logSpy.hasBeenCalled {
+		t.Errorf("unexpected log '%v'", logSpy.lastMessage)
+	}
+}
+
+func TestKeyProviderReturnsMoreKeysThanAllowed_Returns500AndLogs(t *testing.T) {
+	keys := make(staticKeys, 0, 11)
+	for i := 0; i < 10; i++ {
+		keys = append(keys, []byte(fmt.Sprintf("key %v", i)))
+	}
+	keys = append(keys, signatureKey)
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", nil, logSpy.logError, tenant.WithKeyProvider(keys), tenant.WithMaxKeysPerRequest(10))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusInternalServerError); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+	if err := logSpy.assertLogContains("too many keys"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestKeyProviderReturnsAllowedNumberOfKeys_ServesRequest(t *testing.T) {
+	keys := staticKeys{rotatedSignatureKey, signatureKey}
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", nil, nil, tenant.WithKeyProvider(keys), tenant.WithMaxKeysPerRequest(2))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+}
+
+type staticKeys [][]byte
+
+func (k staticKeys) Keys(ctx context.Context, tenantId string) ([][]byte, error) {
+	return k, nil
+}
+
+func TestKeyRotation_AcceptsPreviousKeyOnlyBeforeOverlapEnds(t *testing.T) {
+	overlapUntil := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name string
+		now  time.Time
+		key  []byte
+		want int
+	}{
+		{"current key before overlap ends", overlapUntil.Add(-time.Second), rotatedSignatureKey, http.StatusOK},
+		{"previous key before overlap ends", overlapUntil.Add(-time.Second), signatureKey, http.StatusOK},
+		{"current key when overlap ends", overlapUntil, rotatedSignatureKey, http.StatusOK},
+		{"previous key when overlap ends", overlapUntil, signatureKey, http.StatusForbidden},
+		{"previous key after overlap ended", overlapUntil.Add(time.Second), signatureKey, http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := func() time.Time { return tc.now }
+			addToCtx := tenant.AddToCtx("", nil, nil, tenant.WithKeyRotation(rotatedSignatureKey, signatureKey, overlapUntil), tenant.WithClock(clock))
+
+			if status := serveWithKey(t, addToCtx, tc.key); status != tc.want {
+				t.Errorf("got wrong status code: got %v want %v", status, tc.want)
+			}
+		})
+	}
+}
+
+func TestKeyRotationAndVerificationCache_RejectsCachedPreviousKeyAfterOverlapEnds(t *testing.T) {
+	overlapUntil := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	now := overlapUntil.Add(-time.Second)
+	clock := func() time.Time { return now }
+	addToCtx := tenant.AddToCtx("", nil, nil, tenant.WithKeyRotation(rotatedSignatureKey, signatureKey, overlapUntil), tenant.WithVerificationCache(10), tenant.WithClock(clock))
+
+	if status := serveWithKey(t, addToCtx, signatureKey); status != http.StatusOK {
+		t.Errorf("got wrong status code before overlap ends: got %v want %v", status, http.StatusOK)
+	}
+	now = overlapUntil
+	if status := serveWithKey(t, addToCtx, signatureKey); status != http.StatusForbidden {
+		t.Errorf("got wrong status code after overlap ended: got %v want %v", status, http.StatusForbidden)
+	}
+}
+
+func TestKeyProviderAndVerificationCache_RejectsCachedSignatureOfRevokedKey(t *testing.T) {
+	keys := staticKeys{rotatedSignatureKey, signatureKey}
+	addToCtx := tenant.AddToCtx("", nil, nil, tenant.WithKeyProvider(&keys), tenant.WithVerificationCache(10))
+
+	if status := serveWithKey(t, addToCtx, signatureKey); status != http.StatusOK {
+		t.Errorf("got wrong status code before key has been revoked: got %v want %v", status, http.StatusOK)
+	}
+	keys = staticKeys{rotatedSignatureKey}
+	if status := serveWithKey(t, addToCtx, signatureKey); status != http.StatusForbidden {
+		t.Errorf("got wrong status code after key has been revoked: got %v want %v", status, http.StatusForbidden)
+	}
+}
+
+func TestEmptyCurrentKey_KeyRotation_ReturnsError(t *testing.T) {
+	if _, err := tenant.NewVerifier(tenant.WithKeyRotation(nil, signatureKey, time.Now())); err == nil {
+		t.Error("expected an error for an empty current key")
+	}
+}
+
+type flakyKeyProvider struct {
+	failures int
+	calls    int
+}
+
+func (p *flakyKeyProvider) Keys(ctx context.Context, tenantId string) ([][]byte, error) {
+	p.calls++
+	if p.calls <= p.failures {
+		return nil, errors.New("secret store unavailable")
+	}
+	return [][]byte{signatureKey}, nil
+}
+
+func TestProviderFailingOnce_KeyLookupRetry_Returns200(t *testing.T) {
+	provider := &flakyKeyProvider{failures: 1}
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", nil, nil, tenant.WithKeyProvider(provider), tenant.WithKeyLookupRetry(3, time.Millisecond))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if provider.calls != 2 {
+		t.Errorf("got wrong number of key lookups: got %v want %v", provider.calls, 2)
+	}
+}
+
+func TestProviderFailingMoreOftenThanAttempts_KeyLookupRetry_Returns500(t *testing.T) {
+	provider := &flakyKeyProvider{failures: 3}
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", nil, nil, tenant.WithKeyProvider(provider), tenant.WithKeyLookupRetry(3, time.Millisecond))(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusInternalServerError); err != nil {
+		t.Error(err)
+	}
+	if provider.calls != 3 {
+		t.Errorf("got wrong number of key lookups: got %v want %v", provider.calls, 3)
+	}
+}
+
+func TestDeadlineBeforeBackoff_KeyLookupRetry_DoesntRetry(t *testing.T) {
+	provider := &flakyKeyProvider{failures: 1}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	req := signedRequest(t, "https://sample.example.com", "a12be5").WithContext(ctx)
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", nil, nil, tenant.WithKeyProvider(provider), tenant.WithKeyLookupRetry(3, time.Hour))(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusInternalServerError); err != nil {
+		t.Error(err)
+	}
+	if provider.calls != 1 {
+		t.Errorf("got wrong number of key lookups: got %v want %v", provider.calls, 1)
+	}
+}
+
+func pepper(key []byte) []byte {
+	return append([]byte("deployment-salt:"), key...)
+}
+
+func TestSaltedKeySignature_KeyTransform(t *testing.T) {
+	salted := pepper(signatureKey)
+	testCases := []struct {
+		name    string
+		options []tenant.Option
+		want    int
+	}{
+		{"static key with transform", []tenant.Option{tenant.WithKeyTransform(pepper)}, http.StatusOK},
+		{"key provider with transform", []tenant.Option{tenant.WithKeyProvider(staticKeys{signatureKey}), tenant.WithKeyTransform(pepper)}, http.StatusOK},
+		{"static key without transform", nil, http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := signedRequest(t, "https://sample.example.com", "a12be5")
+			req.Header.Set(signatureHeader, base64Signature("https://sample.example.coma12be5", salted))
+			responseSpy := responseSpy{httptest.NewRecorder()}
+
+			tenant.AddToCtx("", signatureKey, nil, tc.options...)(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+			if err := responseSpy.assertStatusCodeIs(tc.want); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestStaticKeys_KeyTransform_TransformsOnce(t *testing.T) {
+	calls := 0
+	transform := func(key []byte) []byte {
+		calls++
+		return pepper(key)
+	}
+	addToCtx := tenant.AddToCtx("", signatureKey, nil, tenant.WithKeyTransform(transform))
+
+	for i := 0; i < 3; i++ {
+		req := signedRequest(t, "https://sample.example.com", "a12be5")
+		req.Header.Set(signatureHeader, base64Signature("https://sample.example.coma12be5", pepper(signatureKey)))
+		addToCtx(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if calls != 1 {
+		t.Errorf("got wrong number of transformations: got %v want %v", calls, 1)
+	}
+}
+
+func TestSignRequest_KeyTransform_SignsWithTransformedKey(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+
+	if err := tenant.SignRequest(req, signatureKey, tenant.WithKeyTransform(pepper)); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := base64Signature("https://sample.example.coma12be5", pepper(signatureKey)); req.Header.Get(signatureHeader) != want {
+		t.Errorf("got wrong signature: got %v want %v", req.Header.Get(signatureHeader), want)
+	}
+}
+
+func TestKeys_MatchedKeyReporter_ReportsIndexOfMatchingKey(t *testing.T) {
+	testCases := []struct {
+		name string
+		key  []byte
+		want []int
+	}{
+		{"current key", signatureKey, []int{0}},
+		{"second key", rotatedSignatureKey, []int{1}},
+		{"unknown key", []byte("unknown signature key of 32 bytes"), nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := signedRequest(t, "https://sample.example.com", "a12be5")
+			req.Header.Set(signatureHeader, base64Signature("https://sample.example.coma12be5", tc.key))
+			var reported []int
+			report := func(ctx context.Context, index int) {
+				reported = append(reported, index)
+			}
+			v := tenant.MustNewVerifier(tenant.WithSignatureSecretKeys(signatureKey, rotatedSignatureKey), tenant.WithMatchedKeyReporter(report))
+
+			v.Middleware(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), req)
+
+			if !reflect.DeepEqual(reported, tc.want) {
+				t.Errorf("got wrong reported key indexes: got %v want %v", reported, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package tenant_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestAddToCtxOnGorillaMuxSubrouter_PathVariableAndTenantAreAvailable(t *testing.T) {
+	var pathId, tenantId string
+	router := mux.NewRouter()
+	subrouter := router.PathPrefix("/myresource").Subrouter()
+	logSpy := loggerSpy{}
+	subrouter.Use(tenant.AddToCtx("", signatureKey, logSpy.logError))
+	subrouter.HandleFunc("/{id}", func(rw http.ResponseWriter, r *http.Request) {
+		pathId = mux.Vars(r)["id"]
+		tenantId, _ = tenant.IdFromCtx(r.Context())
+	})
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.URL.Path = "/myresource/4711"
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	router.ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if pathId != "4711" {
+		t.Errorf("got wrong path variable: got %v want %v", pathId, "4711")
+	}
+	if tenantId != "a12be5" {
+		t.Errorf("got wrong tenantId from context: got %v want %v", tenantId, "a12be5")
+	}
+}
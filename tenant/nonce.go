@@ -0,0 +1,130 @@
+package tenant
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const nonceHeader = "x-dv-nonce"
+
+// clockCtxKey is the key of the clock of the Verifier in the context which is passed to the NonceStore.
+const clockCtxKey = contextKey("clock")
+
+// NonceStore is an interface representing the ability to record the nonces of requests which have been seen.
+type NonceStore interface {
+	// Add records the nonce of the tenant until expiry. It reports false if the nonce has already been
+	// recorded for the tenant and hasn't expired yet.
+	Add(ctx context.Context, tenantId string, nonce string, expiry time.Time) (bool, error)
+}
+
+// WithNonceStore requires the header x-dv-nonce which is appended to the signed message (use SignRequest
+// with the same option to sign such requests). The nonce must be unique per tenant within the replay window
+// (cf. WithReplayWindow) so that each request is processed at most once.
+//
+// Requests without nonce are rejected with status code 403 and requests with a nonce which has already been
+// recorded in the store with status code 409. The option must be combined with WithTimestampInMessage or
+// WithSignedExpiryHeader so that a nonce only has to be recorded until the request would be rejected anyway. The nonce is recorded after all other checks, including
+// WithTenantActiveCheck, have been passed. For a JWT (cf. WithJWTSource) the claim jti is used instead of the
+// header; JWTs without it are rejected with status code 401. Cookies (cf. WithCookieSource) are reused for a
+// whole session, so they aren't checked. Only the audit event of the accepted request is recorded afterwards,
+// so with WithFailClosedOnAuditError a request which fails because of the audit sink consumes its nonce.
+func WithNonceStore(store NonceStore) Option {
+	return func(v *Verifier) error {
+		if store == nil {
+			return errors.New("nonce store must not be nil")
+		}
+		v.nonceStore = store
+		return nil
+	}
+}
+
+// checkNonce records the nonce of the request and rejects requests whose nonce has been seen before. The nonce
+// is recorded until the request would be rejected because of its signed expiry or timestamp, or for the replay
+// window if the request contains neither.
+func (v *Verifier) checkNonce(ctx context.Context, claims sourceClaims) *verificationError {
+	if claims.nonce == "" {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("nonce header '%v' is missing", nonceHeader), nil}
+	}
+	expiry := v.now().Add(v.replayWindow)
+	if !claims.issuedAt.IsZero() {
+		expiry = claims.issuedAt.Add(v.replayWindow)
+	}
+	if !claims.expiry.IsZero() && (claims.issuedAt.IsZero() || claims.expiry.Before(expiry)) {
+		expiry = claims.expiry
+	}
+	ctx = context.WithValue(ctx, clockCtxKey, v.now)
+	added, err := v.nonceStore.Add(ctx, claims.tenantId, claims.nonce, expiry)
+	if err != nil {
+		return &verificationError{http.StatusInternalServerError, fmt.Sprintf("recording nonce '%v' because: %v", claims.nonce, err), nil}
+	}
+	if !added {
+		return &verificationError{http.StatusConflict, fmt.Sprintf("duplicate nonce '%v' for TenantId '%v'", claims.nonce, v.loggedTenantId(claims.tenantId)), nil}
+	}
+	return nil
+}
+
+// MemoryNonceStore is a NonceStore which keeps the nonces in memory. It is suitable for a single instance
+// of an App. Expired nonces are removed when nonces are added according to the clock configured by WithClock.
+// Only the expired nonces are visited, so adding a nonce takes logarithmic time in the number of recorded nonces.
+type MemoryNonceStore struct {
+	mu      sync.Mutex // protects the following fields
+	expires map[string]time.Time
+	queue   nonceQueue
+}
+
+// NewMemoryNonceStore creates a new empty MemoryNonceStore.
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		expires: map[string]time.Time{},
+	}
+}
+
+// Add records the nonce of the tenant until expiry.
+func (s *MemoryNonceStore) Add(ctx context.Context, tenantId string, nonce string, expiry time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if clock, ok := ctx.Value(clockCtxKey).(func() time.Time); ok {
+		now = clock()
+	}
+	for len(s.queue) > 0 && !now.Before(s.queue[0].expiry) {
+		delete(s.expires, heap.Pop(&s.queue).(nonceEntry).key)
+	}
+	key := tenantId + "\x00" + nonce
+	if _, seen := s.expires[key]; seen {
+		return false, nil
+	}
+	s.expires[key] = expiry
+	heap.Push(&s.queue, nonceEntry{key, expiry})
+	return true, nil
+}
+
+// nonceEntry is a recorded nonce in the nonceQueue.
+type nonceEntry struct {
+	key    string
+	expiry time.Time
+}
+
+// nonceQueue is a min-heap of nonces ordered by their expiry. It implements heap.Interface.
+type nonceQueue []nonceEntry
+
+func (q nonceQueue) Len() int           { return len(q) }
+func (q nonceQueue) Less(i, j int) bool { return q[i].expiry.Before(q[j].expiry) }
+func (q nonceQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *nonceQueue) Push(x interface{}) {
+	*q = append(*q, x.(nonceEntry))
+}
+
+func (q *nonceQueue) Pop() interface{} {
+	old := *q
+	entry := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return entry
+}
@@ -0,0 +1,195 @@
+package tenant_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+const nonceHeader = "x-dv-nonce"
+
+func TestFirstTimeNonce_NonceStore_Returns200(t *testing.T) {
+	store := tenant.NewMemoryNonceStore()
+	req := nonceRequest(t, store, "3f9c1a", time.Now().Add(time.Minute))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignedExpiryHeader(expiryHeader), tenant.WithNonceStore(store))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReplayedNonce_NonceStore_Returns409(t *testing.T) {
+	store := tenant.NewMemoryNonceStore()
+	logSpy := loggerSpy{}
+	handler := tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithSignedExpiryHeader(expiryHeader), tenant.WithNonceStore(store))
+	handler(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), nonceRequest(t, store, "3f9c1a", time.Now().Add(time.Minute)))
+
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	handler(&handlerSpy).ServeHTTP(responseSpy, nonceRequest(t, store, "3f9c1a", time.Now().Add(time.Minute)))
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusConflict); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+	if err := logSpy.assertLogContains("duplicate"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTamperedNonce_NonceStore_Returns403(t *testing.T) {
+	store := tenant.NewMemoryNonceStore()
+	req := nonceRequest(t, store, "3f9c1a", time.Now().Add(time.Minute))
+	req.Header.Set(nonceHeader, "other")
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignedExpiryHeader(expiryHeader), tenant.WithNonceStore(store))(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMissingNonce_NonceStore_Returns403(t *testing.T) {
+	store := tenant.NewMemoryNonceStore()
+	req := nonceRequest(t, store, "", time.Now().Add(time.Minute))
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignedExpiryHeader(expiryHeader), tenant.WithNonceStore(store))(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDisallowedHost_NonceStore_DoesntRecordNonce(t *testing.T) {
+	store := tenant.NewMemoryNonceStore()
+	rejecting := tenant.AddToCtx("", signatureKey, nil, tenant.WithSignedExpiryHeader(expiryHeader), tenant.WithNonceStore(store), tenant.WithAllowedHosts("other.example.com"))
+	rejectedSpy := responseSpy{httptest.NewRecorder()}
+	rejecting(&handlerSpy{}).ServeHTTP(rejectedSpy, nonceRequest(t, store, "3f9c1a", time.Now().Add(time.Minute)))
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignedExpiryHeader(expiryHeader), tenant.WithNonceStore(store))(&handlerSpy{}).ServeHTTP(responseSpy, nonceRequest(t, store, "3f9c1a", time.Now().Add(time.Minute)))
+
+	if err := rejectedSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInactiveTenant_NonceStore_DoesntRecordNonce(t *testing.T) {
+	store := tenant.NewMemoryNonceStore()
+	isActive := func(ctx context.Context, tenantId string) (bool, error) {
+		return false, nil
+	}
+	rejectedSpy := responseSpy{httptest.NewRecorder()}
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignedExpiryHeader(expiryHeader), tenant.WithNonceStore(store), tenant.WithTenantActiveCheck(isActive))(&handlerSpy{}).ServeHTTP(rejectedSpy, nonceRequest(t, store, "3f9c1a", time.Now().Add(time.Minute)))
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignedExpiryHeader(expiryHeader), tenant.WithNonceStore(store))(&handlerSpy{}).ServeHTTP(responseSpy, nonceRequest(t, store, "3f9c1a", time.Now().Add(time.Minute)))
+
+	if err := rejectedSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNonceStoreWithoutTimestampOrExpiry_Returns500(t *testing.T) {
+	store := tenant.NewMemoryNonceStore()
+	req := nonceRequest(t, store, "3f9c1a", time.Now().Add(time.Minute))
+	logSpy := loggerSpy{}
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithNonceStore(store))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusInternalServerError); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+	if err := logSpy.assertLogContains("nonce store requires"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReplayedNonceAccordingToClock_NonceStore_Returns409(t *testing.T) {
+	// the request expired according to time.Now but not according to the clock of the middleware
+	now := time.Now().Add(-time.Hour)
+	store := tenant.NewMemoryNonceStore()
+	handler := tenant.AddToCtx("", signatureKey, nil, tenant.WithClock(func() time.Time { return now }), tenant.WithSignedExpiryHeader(expiryHeader), tenant.WithNonceStore(store))
+	handler(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), nonceRequest(t, store, "3f9c1a", now.Add(time.Minute)))
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	handler(&handlerSpy{}).ServeHTTP(responseSpy, nonceRequest(t, store, "3f9c1a", now.Add(time.Minute)))
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusConflict); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	store := tenant.NewMemoryNonceStore()
+	ctx := context.Background()
+	add := func(tenantId, nonce string, expiry time.Time) bool {
+		t.Helper()
+		added, err := store.Add(ctx, tenantId, nonce, expiry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return added
+	}
+
+	if !add("a12be5", "1", time.Now().Add(time.Minute)) {
+		t.Error("first nonce should have been added")
+	}
+	if add("a12be5", "1", time.Now().Add(time.Minute)) {
+		t.Error("duplicate nonce should not have been added")
+	}
+	if !add("b34cd6", "1", time.Now().Add(time.Minute)) {
+		t.Error("same nonce of another tenant should have been added")
+	}
+	add("a12be5", "2", time.Now().Add(-time.Minute))
+	if !add("a12be5", "2", time.Now().Add(time.Minute)) {
+		t.Error("expired nonce should have been added again")
+	}
+	add("a12be5", "3", time.Now().Add(time.Hour))
+	add("a12be5", "4", time.Now().Add(-time.Minute))
+	if add("a12be5", "3", time.Now().Add(time.Minute)) {
+		t.Error("nonce should not have been removed with an earlier expired nonce")
+	}
+}
+
+func nonceRequest(t *testing.T, store tenant.NonceStore, nonce string, expiry time.Time) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest("POST", "/webhook", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(systemBaseUriHeader, "https://sample.example.com")
+	req.Header.Set(tenantIdHeader, "a12be5")
+	req.Header.Set(nonceHeader, nonce)
+	req.Header.Set(expiryHeader, strconv.FormatInt(expiry.Unix(), 10))
+	if err := tenant.SignRequest(req, signatureKey, tenant.WithSignedExpiryHeader(expiryHeader), tenant.WithNonceStore(store)); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
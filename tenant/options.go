@@ -0,0 +1,644 @@
+package tenant
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Verifier holds the configuration of the tenant middleware.
+type Verifier struct {
+	droppedOutcomes            int64 // accessed atomically, first field for 64-bit alignment on 32-bit platforms
+	defaultSystemBaseUri       string
+	keyProvider                KeyProvider
+	scheme                     SignatureScheme
+	logger                     func(ctx context.Context, message string)
+	auditSink                  AuditSink
+	failClosedOnAuditError     bool
+	accessLog                  func(ctx context.Context, status int)
+	maxBaseUriLength           int
+	requireBothIdentityHeaders bool
+	initiatorHeaders           []string
+	bodySignature              bool
+	streamingBodySignature     bool
+	formBodySignature          bool
+	signatureVersions          []int
+	now                        func() time.Time
+	successLogger              func(ctx context.Context, message string)
+	jwtKeyFunc                 JWTKeyFunc
+	emptyTenantIdAsDefault     bool
+	missingKeyStatus           int
+	signedInitiator            bool
+	cookieName                 string
+	maxKeysPerRequest          int
+	testModeRequireHeaders     bool
+	normalizeForwarded         bool
+	assumeHTTPS                bool
+	signMethodAndPath          bool
+	validateForwardedHost      bool
+	additionalSignedData       func(r *http.Request) string
+	minSignatureVersion        int
+	responseBaggage            bool
+	signedExpiryHeader         string
+	allowedHosts               map[string]bool
+	initiatorFallback          bool
+	tenantActive               func(ctx context.Context, tenantId string) (bool, error)
+	verificationCache          *verificationCache
+	forcedScheme               string
+	expvarCounters             *expvarCounters
+	timestampHeader            string
+	timestampLayout            string
+	replayWindow               time.Duration
+	enforcementPolicy          func(tenantId string) bool
+	nonceStore                 NonceStore
+	tenantLogger               func(info TenantInfo) interface{}
+	tenantLoggerKey            interface{}
+	outcomes                   chan<- Outcome
+	redactTenantLog            func(tenantId string) bool
+	signQueryParams            bool
+	maxBodySize                int64
+	hexSignatureEncoding       bool
+	signHost                   bool
+	canonicalJSONFields        []string
+	keyLookupAttempts          int
+	keyLookupBackoff           time.Duration
+	uuidTenantId               bool
+	signForwardedChain         bool
+	keyTransform               func(key []byte) []byte
+	serverTiming               bool
+	allowedInitiators          map[string]bool
+	requireInitiator           bool
+	keyIdHeader                string
+	deriveKey                  func(kid string) []byte
+	futureSkewTolerance        time.Duration
+	outboundKey                []byte
+	whitespaceCanonicalization bool
+	matchedKeyReporter         func(ctx context.Context, index int)
+	initiatorSignature         bool
+	missingKeyHandler          http.Handler
+}
+
+// defaultMaxBaseUriLength is the maximum length of the systemBaseUri, tenantId and forwarded headers
+// which is accepted if WithMaxBaseUriLength is not used.
+const defaultMaxBaseUriLength = 2048
+
+// Option configures the tenant middleware.
+type Option func(*Verifier) error
+
+// AuditEvent describes the outcome of the tenant verification of a single request.
+type AuditEvent struct {
+	// TenantId is the tenant id claimed by the request. It is empty if the header was not present.
+	TenantId string
+	// SystemBaseUri is the systemBaseUri claimed by the request. It is empty if the header was not present.
+	SystemBaseUri string
+	// Accepted reports whether the request has been passed to the next handler.
+	Accepted bool
+	// Reason describes why the request has been rejected.
+	Reason string
+}
+
+// AuditSink is an interface representing the ability to record audit events
+type AuditSink interface {
+	// Record stores the event. An error is returned if the event could not be recorded.
+	Record(ctx context.Context, event AuditEvent) error
+}
+
+// WithAuditSink records an AuditEvent for every request which passes the middleware.
+//
+// By default errors of the sink are logged but the request is served anyway.
+// Use WithFailClosedOnAuditError to reject requests which could not be audited.
+func WithAuditSink(sink AuditSink) Option {
+	return func(v *Verifier) error {
+		v.auditSink = sink
+		return nil
+	}
+}
+
+// WithFailClosedOnAuditError rejects requests with status code 500 if the AuditSink
+// could not record the event for an otherwise valid request.
+func WithFailClosedOnAuditError() Option {
+	return func(v *Verifier) error {
+		v.failClosedOnAuditError = true
+		return nil
+	}
+}
+
+// WithAccessLog invokes accessLog after each request with the status code which has been written.
+// If the request has been passed to the next handler the context contains the tenant information.
+// Otherwise the context of the original request is used.
+func WithAccessLog(accessLog func(ctx context.Context, status int)) Option {
+	return func(v *Verifier) error {
+		v.accessLog = accessLog
+		return nil
+	}
+}
+
+// WithMaxBaseUriLength sets the maximum accepted length of the systemBaseUri header.
+// The same bound applies to the tenantId and the forwarded headers.
+// Requests with longer headers are rejected with status code 400 before the signature is validated.
+// Defaults to 2048.
+func WithMaxBaseUriLength(n int) Option {
+	return func(v *Verifier) error {
+		if n <= 0 {
+			return fmt.Errorf("max base uri length must be positive but is %v", n)
+		}
+		v.maxBaseUriLength = n
+		return nil
+	}
+}
+
+// WithRequireBothIdentityHeaders rejects requests with status code 400 which contain only one of the
+// headers x-dv-baseuri and x-dv-tenant-id. Without this option a missing tenantId defaults to "0"
+// and a missing systemBaseUri to the defaultSystemBaseUri.
+// Requests which contain neither header are still accepted.
+func WithRequireBothIdentityHeaders() Option {
+	return func(v *Verifier) error {
+		v.requireBothIdentityHeaders = true
+		return nil
+	}
+}
+
+// WithInitiatorHeaderPriority sets the headers which are consulted in the given order to determine
+// the initiatorSystemBaseUri. Headers which are not given are ignored entirely.
+// Supported headers are "Forwarded" and "X-Forwarded-Host" (case-insensitive).
+// If none of the headers yields a host the systemBaseUri is used.
+// Defaults to "Forwarded", "X-Forwarded-Host".
+func WithInitiatorHeaderPriority(headers ...string) Option {
+	return func(v *Verifier) error {
+		initiatorHeaders := make([]string, 0, len(headers))
+		for _, header := range headers {
+			h := strings.ToLower(header)
+			if h != forwardedHeader && h != xForwardedHostHeader {
+				return fmt.Errorf("header '%v' is not supported to determine the initiator", header)
+			}
+			initiatorHeaders = append(initiatorHeaders, h)
+		}
+		v.initiatorHeaders = initiatorHeaders
+		return nil
+	}
+}
+
+// WithInitiatorFallbackToSystemBaseUri controls which initiatorSystemBaseUri is stored on the context if the
+// request doesn't contain forwarded headers. If fallback is true, which is the default, the systemBaseUri of the
+// request or the defaultSystemBaseUri is used. If fallback is false the initiatorSystemBaseUri is left empty
+// so that handlers can tell whether the initiator has been forwarded.
+func WithInitiatorFallbackToSystemBaseUri(fallback bool) Option {
+	return func(v *Verifier) error {
+		v.initiatorFallback = fallback
+		return nil
+	}
+}
+
+// WithDefaultSystemBaseUri sets the systemBaseUri which is used if the request doesn't contain
+// the header x-dv-baseuri.
+func WithDefaultSystemBaseUri(defaultSystemBaseUri string) Option {
+	return func(v *Verifier) error {
+		v.defaultSystemBaseUri = defaultSystemBaseUri
+		return nil
+	}
+}
+
+// WithSignatureVersions sets the accepted signature versions. The signature of version n is
+// transmitted in the header x-dv-sig-n. If a request contains signatures of several accepted versions
+// the highest version is verified.
+//
+// Supported versions are 1 and 2. Defaults to 1.
+func WithSignatureVersions(versions ...int) Option {
+	return func(v *Verifier) error {
+		if len(versions) == 0 {
+			return errors.New("at least one signature version must be accepted")
+		}
+		for _, version := range versions {
+			if version != 1 && version != 2 {
+				return fmt.Errorf("signature version %v is not supported", version)
+			}
+		}
+		signatureVersions := append([]int(nil), versions...)
+		sort.Ints(signatureVersions)
+		v.signatureVersions = signatureVersions
+		return nil
+	}
+}
+
+// WithMinSignatureVersion rejects signatures whose version is below the given version with status code 403.
+// This enforces the completion of a migration to a newer signature version. The version must also be
+// accepted by WithSignatureVersions, e.g. WithSignatureVersions(1, 2) together with WithMinSignatureVersion(2).
+func WithMinSignatureVersion(version int) Option {
+	return func(v *Verifier) error {
+		if version != 1 && version != 2 {
+			return fmt.Errorf("signature version %v is not supported", version)
+		}
+		v.minSignatureVersion = version
+		return nil
+	}
+}
+
+// WithClock sets the function which returns the current time. Defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(v *Verifier) error {
+		if now == nil {
+			return errors.New("clock must not be nil")
+		}
+		v.now = now
+		return nil
+	}
+}
+
+// WithSuccessLogger logs every accepted request including the tenant id using the given logger.
+// By default only rejected requests are logged.
+func WithSuccessLogger(logger func(ctx context.Context, message string)) Option {
+	return func(v *Verifier) error {
+		v.successLogger = logger
+		return nil
+	}
+}
+
+// WithEmptyTenantIdAsDefault treats a present but empty header x-dv-tenant-id like a missing header,
+// that is the tenant "0" is used. By default such requests are rejected with status code 400
+// because an empty header most likely indicates a broken caller.
+func WithEmptyTenantIdAsDefault() Option {
+	return func(v *Verifier) error {
+		v.emptyTenantIdAsDefault = true
+		return nil
+	}
+}
+
+// WithMissingKeyStatus sets the status code which is returned for signed requests if no
+// signature secret key has been configured. Use e.g. 503 to trigger a failover of the load balancer.
+// Defaults to 500.
+func WithMissingKeyStatus(code int) Option {
+	return func(v *Verifier) error {
+		if code < 400 || code > 599 {
+			return fmt.Errorf("missing key status must be an error status code but is %v", code)
+		}
+		v.missingKeyStatus = code
+		return nil
+	}
+}
+
+// WithMissingKeyHandler invokes handler instead of responding with the status code of WithMissingKeyStatus if a
+// signed request arrives but no signature secret key has been configured, e.g. to serve a limited read-only
+// response while the keys can't be loaded from a secret store. The context passed to handler contains the
+// tenant information extracted from the tenant headers which has NOT been verified (cf. SignatureVerifiedFromCtx),
+// so handler must not trust it. The request is still logged and counted as rejected.
+func WithMissingKeyHandler(handler http.Handler) Option {
+	return func(v *Verifier) error {
+		if handler == nil {
+			return errors.New("missing key handler must not be nil")
+		}
+		v.missingKeyHandler = handler
+		return nil
+	}
+}
+
+// serveMissingKey invokes the handler of WithMissingKeyHandler with the unverified tenant information of req.
+func (v *Verifier) serveMissingKey(rw http.ResponseWriter, req *http.Request) {
+	info, _ := v.headerInfo(req, v.signedHeaderValue(req.Header, systemBaseUriHeader), v.signedHeaderValue(req.Header, tenantIdHeader), false)
+	v.missingKeyHandler.ServeHTTP(rw, req.WithContext(newCtx(req.Context(), info)))
+}
+
+// WithSignedInitiator requires the initiatorSystemBaseUri derived from the forwarded headers to be part
+// of the signed message (cf. SignedMessage). Requests without forwarded headers are signed as usual.
+func WithSignedInitiator() Option {
+	return func(v *Verifier) error {
+		v.signedInitiator = true
+		return nil
+	}
+}
+
+// WithTestModeRequireHeaders is meant for integration tests which don't have the signature secret key.
+// The headers x-dv-baseuri and x-dv-tenant-id are required and added to the context but their signature
+// is not verified. Requests without these headers are rejected with status code 400.
+// A warning is logged when the middleware is created. Never use this option in production.
+func WithTestModeRequireHeaders() Option {
+	return func(v *Verifier) error {
+		v.testModeRequireHeaders = true
+		return nil
+	}
+}
+
+// WithNormalizeForwarded replaces the headers Forwarded and X-Forwarded-Host of the request which is passed
+// to the next handler with a single Forwarded header in the format host="<host>";proto=https. The host is
+// taken from the initiatorSystemBaseUri as determined by WithInitiatorHeaderPriority. This is meant for apps
+// which forward requests to other services. Requests without forwarded headers are not changed.
+func WithNormalizeForwarded() Option {
+	return func(v *Verifier) error {
+		v.normalizeForwarded = true
+		return nil
+	}
+}
+
+// WithAssumeHTTPS stores a systemBaseUri without scheme, e.g. "x.example.com", as "https://x.example.com"
+// on the context. The signature is still verified over the value of the header as it has been sent.
+func WithAssumeHTTPS() Option {
+	return func(v *Verifier) error {
+		v.assumeHTTPS = true
+		return nil
+	}
+}
+
+// WithSignMethodAndPath requires the method and the path of the request to be part of the signed message
+// so that a signature is only valid for a specific operation. E.g. a signed GET request can't be replayed
+// as DELETE request. For signature version 1 the method and path are appended to the message without
+// separator, e.g. "https://x.example.comabc123GET/myresource". Use SignRequest to sign such requests.
+func WithSignMethodAndPath() Option {
+	return func(v *Verifier) error {
+		v.signMethodAndPath = true
+		return nil
+	}
+}
+
+// WithSignQueryParams requires the query parameters of the request to be part of the signed message so that
+// a signature is only valid for specific parameters, e.g. of a GET callback. The parameters are appended in
+// their canonical form, i.e. url encoded and sorted by name and value, e.g. "a=1&a=2&b=". So the order of
+// the parameters doesn't matter whereas parameters with empty values are signed like other parameters.
+// Use SignRequest to sign such requests.
+func WithSignQueryParams() Option {
+	return func(v *Verifier) error {
+		v.signQueryParams = true
+		return nil
+	}
+}
+
+// canonicalQuery returns the query parameters url encoded and sorted by name and value.
+func canonicalQuery(query url.Values) string {
+	params := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, url.QueryEscape(name)+"="+url.QueryEscape(value))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// WithSignHost requires the host of the request, i.e. the Host header, to be part of the signed message so that
+// a signature issued for one virtual host can't be replayed against another virtual host of the same app. The host
+// is appended in lowercase. If the request doesn't contain a Host header, e.g. a client request, the host of
+// the url is used. Use SignRequest to sign such requests.
+func WithSignHost() Option {
+	return func(v *Verifier) error {
+		v.signHost = true
+		return nil
+	}
+}
+
+// WithCanonicalJSONSigning signs a canonical JSON object of the values of the given headers instead of the
+// systemBaseUri and the tenantId. The names of the headers are the keys of the object in lowercase. The keys are
+// sorted and the object doesn't contain any whitespace or HTML escapes. Headers which are missing have an empty
+// value. E.g. the headers x-dv-tenant-id and x-dv-baseuri result in the signed message
+//
+//	{"x-dv-baseuri":"https://sample.example.com","x-dv-tenant-id":"a12be5"}
+//
+// Values of other options, e.g. of WithSignMethodAndPath, are appended as usual. Use SignRequest to sign such
+// requests. An error is returned if no headers or duplicate headers are given.
+func WithCanonicalJSONSigning(fields ...string) Option {
+	return func(v *Verifier) error {
+		if len(fields) == 0 {
+			return errors.New("canonical JSON signing requires at least one header")
+		}
+		names := make([]string, 0, len(fields))
+		seen := map[string]bool{}
+		for _, field := range fields {
+			name := strings.ToLower(strings.TrimSpace(field))
+			if name == "" {
+				return errors.New("header names of canonical JSON signing must not be empty")
+			}
+			if seen[name] {
+				return fmt.Errorf("header '%v' is used more than once for canonical JSON signing", name)
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+		v.canonicalJSONFields = names
+		return nil
+	}
+}
+
+// canonicalJSON returns the canonical JSON object of the headers configured by WithCanonicalJSONSigning.
+func (v *Verifier) canonicalJSON(req *http.Request) string {
+	values := make(map[string]string, len(v.canonicalJSONFields))
+	for _, name := range v.canonicalJSONFields {
+		values[name] = v.signedHeaderValue(req.Header, name)
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	// a map of strings can always be encoded and the keys are sorted by encoding/json
+	_ = encoder.Encode(values)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// requestHost returns the host of req in lowercase.
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return strings.ToLower(req.Host)
+	}
+	if req.URL != nil {
+		return strings.ToLower(req.URL.Host)
+	}
+	return ""
+}
+
+// WithHexSignatureEncoding expects the signature of the tenant headers to be hex encoded instead of base64
+// encoded, e.g. for callers which send the HMAC as uppercase hex. The hex value is decoded case-insensitively.
+// The signed message is not affected. SignRequest with this option sets a lowercase hex signature.
+func WithHexSignatureEncoding() Option {
+	return func(v *Verifier) error {
+		v.hexSignatureEncoding = true
+		return nil
+	}
+}
+
+// decodeHeaderSignature decodes the signature of the tenant headers according to WithHexSignatureEncoding.
+func (v *Verifier) decodeHeaderSignature(value string) ([]byte, error) {
+	if v.hexSignatureEncoding {
+		return hex.DecodeString(strings.TrimSpace(value))
+	}
+	return decodeSignature(value)
+}
+
+// encodeHeaderSignature encodes the signature of the tenant headers according to WithHexSignatureEncoding.
+func (v *Verifier) encodeHeaderSignature(signature []byte) string {
+	if v.hexSignatureEncoding {
+		return hex.EncodeToString(signature)
+	}
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+// WithValidateForwardedHost ignores hosts in the forwarded headers which are not syntactically valid hosts,
+// e.g. because they contain illegal characters or a path. Such hosts are logged as "invalid forwarded host"
+// and are not used as initiatorSystemBaseUri.
+func WithValidateForwardedHost() Option {
+	return func(v *Verifier) error {
+		v.validateForwardedHost = true
+		return nil
+	}
+}
+
+// WithAdditionalSignedData appends the data returned by the callback to the signed message. This binds the
+// signature to application specific data of the request, e.g. a resource id taken from the path.
+// Nothing is appended if the callback returns an empty string. Use SignRequest with the same option to
+// sign such requests.
+func WithAdditionalSignedData(data func(r *http.Request) string) Option {
+	return func(v *Verifier) error {
+		if data == nil {
+			return errors.New("additional signed data callback must not be nil")
+		}
+		v.additionalSignedData = data
+		return nil
+	}
+}
+
+// WithAllowedHosts rejects requests whose systemBaseUri doesn't have one of the given hosts with status code 403.
+// A host may contain a port, e.g. "x.example.com:8443". Hosts are compared case-insensitively and default
+// ports are ignored (cf. CanonicalBaseUri).
+//
+// The host is always taken from the signed header x-dv-baseuri (or the defaultSystemBaseUri if the header
+// is missing) and never from the Host header of the request (http.Request.Host). So requests are accepted
+// behind gateways which rewrite the Host header as long as the signed systemBaseUri is allowed.
+func WithAllowedHosts(hosts ...string) Option {
+	return func(v *Verifier) error {
+		if len(hosts) == 0 {
+			return errors.New("at least one host must be allowed")
+		}
+		v.allowedHosts = make(map[string]bool, len(hosts))
+		for _, host := range hosts {
+			v.allowedHosts[canonicalHost(uriPrefix+host)] = true
+		}
+		return nil
+	}
+}
+
+// checkAllowedHost rejects the systemBaseUri if its host is not allowed by WithAllowedHosts.
+func (v *Verifier) checkAllowedHost(systemBaseUri string) *verificationError {
+	if v.allowedHosts == nil || v.allowedHosts[canonicalHost(systemBaseUri)] {
+		return nil
+	}
+	return &verificationError{http.StatusForbidden, fmt.Sprintf("host of SystemBaseUri '%v' is not allowed", systemBaseUri), nil}
+}
+
+// WithTenantActiveCheck calls isActive for every request whose signature has been verified (cf. SignatureVerifiedFromCtx).
+// Requests without verified tenant, e.g. requests of the default tenant "0", aren't checked. Requests of inactive
+// tenants are rejected with status code 403 and requests for which the check fails with status code 500.
+// The context passed to isActive is the context of the request so a lookup is canceled if the request is canceled.
+func WithTenantActiveCheck(isActive func(ctx context.Context, tenantId string) (bool, error)) Option {
+	return func(v *Verifier) error {
+		if isActive == nil {
+			return errors.New("tenant active check must not be nil")
+		}
+		v.tenantActive = isActive
+		return nil
+	}
+}
+
+// checkTenantActive rejects the tenant if it is not active according to WithTenantActiveCheck.
+func (v *Verifier) checkTenantActive(ctx context.Context, tenantId string) *verificationError {
+	if v.tenantActive == nil {
+		return nil
+	}
+	active, err := v.tenantActive(ctx, tenantId)
+	if err != nil {
+		return &verificationError{http.StatusInternalServerError, fmt.Sprintf("checking whether TenantId '%v' is active because: %v", v.loggedTenantId(tenantId), err), nil}
+	}
+	if !active {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("tenant inactive: TenantId '%v' is not active", v.loggedTenantId(tenantId)), nil}
+	}
+	return nil
+}
+
+// WithForceScheme stores the systemBaseUri with the given scheme, e.g. "https", regardless of the scheme which
+// has been sent in the header x-dv-baseuri. A warning is logged if the scheme is rewritten. The signature is still
+// verified over the value of the header as it has been sent.
+func WithForceScheme(scheme string) Option {
+	return func(v *Verifier) error {
+		if scheme == "" || strings.ContainsAny(scheme, ":/") {
+			return fmt.Errorf("scheme '%v' is not valid", scheme)
+		}
+		v.forcedScheme = strings.ToLower(scheme)
+		return nil
+	}
+}
+
+// WithEnforcementPolicy decides per tenant whether an invalid signature of the tenant headers is enforced, i.e. the
+// request is rejected with status code 403, or merely logged. This allows to enforce signatures for some tenants
+// while auditing the signatures of other tenants during a staged rollout.
+//
+// The policy is called with the tenant id claimed by the request which hasn't been verified. The decision is logged.
+// Requests of audited tenants are passed to the next handler with the claimed tenant information
+// and SignatureVerifiedFromCtx reports false. By default signatures are enforced for all tenants.
+func WithEnforcementPolicy(enforce func(tenantId string) bool) Option {
+	return func(v *Verifier) error {
+		if enforce == nil {
+			return errors.New("enforcement policy must not be nil")
+		}
+		v.enforcementPolicy = enforce
+		return nil
+	}
+}
+
+// enforces reports whether the signature error vErr is enforced for the tenant.
+func (v *Verifier) enforces(ctx context.Context, tenantId string, vErr *verificationError) bool {
+	if v.enforcementPolicy == nil {
+		return true
+	}
+	if v.enforcementPolicy(tenantId) {
+		v.logger(ctx, fmt.Sprintf("enforcing signature for TenantId '%v'", v.loggedTenantId(tenantId)))
+		return true
+	}
+	v.logger(ctx, fmt.Sprintf("WARNING: accepting request for TenantId '%v' because the signature is only audited for this tenant: %v", v.loggedTenantId(tenantId), vErr.message))
+	return false
+}
+
+// WithTenantLogRedaction replaces the tenant id by a hash in all messages which are logged by the middleware
+// if redact returns true for the tenant id, e.g. for tenants whose identifier must not be logged. The hash is
+// the same for all messages of a tenant so that they can still be correlated. The tenant id in the context
+// and in the AuditEvent is not affected.
+//
+// redact is called with the tenant id of the request, i.e. the tenant id of the headers, the JWT or the cookie.
+// The errors returned by ValidateRequest contain the hash as well. Signed messages, which contain the tenant id,
+// are omitted from the messages of redacted tenants.
+func WithTenantLogRedaction(redact func(tenantId string) bool) Option {
+	return func(v *Verifier) error {
+		if redact == nil {
+			return errors.New("tenant log redaction must not be nil")
+		}
+		v.redactTenantLog = redact
+		return nil
+	}
+}
+
+// redactsTenantId reports whether the tenant id must be redacted according to WithTenantLogRedaction.
+func (v *Verifier) redactsTenantId(tenantId string) bool {
+	return v.redactTenantLog != nil && tenantId != "" && v.redactTenantLog(tenantId)
+}
+
+// loggedTenantId returns the tenant id as it may be logged, i.e. a hash of the tenant id if it must be redacted.
+func (v *Verifier) loggedTenantId(tenantId string) string {
+	if !v.redactsTenantId(tenantId) {
+		return tenantId
+	}
+	hash := sha256.Sum256([]byte(tenantId))
+	return "sha256:" + hex.EncodeToString(hash[:8])
+}
+
+func (v *Verifier) audit(ctx context.Context, event AuditEvent) error {
+	if v.auditSink == nil {
+		return nil
+	}
+	err := v.auditSink.Record(ctx, event)
+	if err != nil {
+		v.logger(ctx, fmt.Sprintf("recording audit event for TenantId '%v' because: %v", v.loggedTenantId(event.TenantId), err))
+	}
+	return err
+}
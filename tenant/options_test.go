@@ -0,0 +1,1074 @@
+package tenant_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestFailingAuditSink_ServesRequestAndLogs(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+	sink := auditSinkSpy{err: errors.New("sink unavailable")}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithAuditSink(&sink))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if !handlerSpy.hasBeenCalled {
+		t.Error("inner handler should have been called")
+	}
+	if err := logSpy.assertLogContains("audit"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFailingAuditSinkAndFailClosed_Returns500(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+	sink := auditSinkSpy{err: errors.New("sink unavailable")}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithAuditSink(&sink), tenant.WithFailClosedOnAuditError())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusInternalServerError); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+	if err := logSpy.assertLogContains("audit"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAuditSink_RecordsAcceptedAndRejectedRequests(t *testing.T) {
+	sink := auditSinkSpy{}
+	logSpy := loggerSpy{}
+	addToCtx := tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithAuditSink(&sink))
+
+	addToCtx(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), signedRequest(t, "https://sample.example.com", "a12be5"))
+	tampered := signedRequest(t, "https://sample.example.com", "a12be5")
+	tampered.Header.Set(tenantIdHeader, "other")
+	addToCtx(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), tampered)
+
+	if len(sink.events) != 2 {
+		t.Fatalf("got %v audit events want %v", len(sink.events), 2)
+	}
+	if !sink.events[0].Accepted || sink.events[0].TenantId != "a12be5" {
+		t.Errorf("got wrong audit event for valid request: %+v", sink.events[0])
+	}
+	if sink.events[1].Accepted || sink.events[1].Reason == "" {
+		t.Errorf("got wrong audit event for tampered request: %+v", sink.events[1])
+	}
+}
+
+func signedRequest(t *testing.T, systemBaseUri, tenantId string) *http.Request {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(systemBaseUriHeader, systemBaseUri)
+	req.Header.Set(tenantIdHeader, tenantId)
+	req.Header.Set(signatureHeader, base64Signature(systemBaseUri+tenantId, signatureKey))
+	return req
+}
+
+type auditSinkSpy struct {
+	events []tenant.AuditEvent
+	err    error
+}
+
+func (spy *auditSinkSpy) Record(ctx context.Context, event tenant.AuditEvent) error {
+	spy.events = append(spy.events, event)
+	return spy.err
+}
+
+func TestAccessLog_ReportsStatusCodeAndTenant(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusNotFound} {
+		req := signedRequest(t, "https://sample.example.com", "a12be5")
+		var loggedStatus int
+		var loggedTenantId string
+		accessLog := func(ctx context.Context, s int) {
+			loggedStatus = s
+			loggedTenantId, _ = tenant.IdFromCtx(ctx)
+		}
+		handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if status != http.StatusOK {
+				http.Error(rw, http.StatusText(status), status)
+				return
+			}
+			_, _ = rw.Write([]byte("hello"))
+		})
+		logSpy := loggerSpy{}
+
+		tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithAccessLog(accessLog))(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+		if loggedStatus != status {
+			t.Errorf("got wrong status code in access log: got %v want %v", loggedStatus, status)
+		}
+		if loggedTenantId != "a12be5" {
+			t.Errorf("got wrong tenantId in access log: got %v want %v", loggedTenantId, "a12be5")
+		}
+	}
+}
+
+func TestAccessLog_ForwardsFlushAndHijack(t *testing.T) {
+	rec := hijackableRecorder{ResponseRecorder: httptest.NewRecorder()}
+	var flushErr, hijackErr, unwrapErr error
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		flusher, ok := rw.(http.Flusher)
+		if !ok {
+			flushErr = errors.New("response writer is not a http.Flusher")
+			return
+		}
+		flusher.Flush()
+		hijacker, ok := rw.(http.Hijacker)
+		if !ok {
+			hijackErr = errors.New("response writer is not a http.Hijacker")
+			return
+		}
+		_, _, hijackErr = hijacker.Hijack()
+		if unwrapper, ok := rw.(interface{ Unwrap() http.ResponseWriter }); !ok || unwrapper.Unwrap() == nil {
+			unwrapErr = errors.New("response writer can't be unwrapped")
+		}
+	})
+	var loggedStatus int
+	accessLog := func(ctx context.Context, s int) { loggedStatus = s }
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithAccessLog(accessLog))(handler).ServeHTTP(&rec, signedRequest(t, "https://sample.example.com", "a12be5"))
+
+	if flushErr != nil {
+		t.Fatal(flushErr)
+	}
+	if !rec.Flushed {
+		t.Error("response should have been flushed")
+	}
+	if hijackErr != errHijacked {
+		t.Errorf("got wrong error from hijack: got %v want %v", hijackErr, errHijacked)
+	}
+	if unwrapErr != nil {
+		t.Error(unwrapErr)
+	}
+	if loggedStatus != http.StatusOK {
+		t.Errorf("got wrong status code in access log: got %v want %v", loggedStatus, http.StatusOK)
+	}
+}
+
+var errHijacked = errors.New("hijacked")
+
+// hijackableRecorder is a ResponseRecorder which supports http.Hijacker.
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r *hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errHijacked
+}
+
+func TestBaseUriLongerThanMaxBaseUriLength_Returns400(t *testing.T) {
+	req := signedRequest(t, "https://"+strings.Repeat("a", 100)+".example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithMaxBaseUriLength(64))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+	if err := logSpy.assertLogContains("baseuri too long"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBaseUriLongerThanDefaultMaxBaseUriLength_Returns400(t *testing.T) {
+	req := signedRequest(t, "https://"+strings.Repeat("a", 1<<20)+".example.com", "a12be5")
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError)(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestForwardedHeaderLongerThanMaxBaseUriLength_Returns400(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(xForwardedHostHeader, strings.Repeat("a", 100)+".example.com")
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithMaxBaseUriLength(64))(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+	if err := logSpy.assertLogContains("forwarded header too long"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRequireBothIdentityHeadersAndOnlyOneHeader_Returns400(t *testing.T) {
+	for header, value := range map[string]string{systemBaseUriHeader: "https://sample.example.com", tenantIdHeader: "a12be5"} {
+		req, err := http.NewRequest("GET", "/myresource/sub", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(header, value)
+		req.Header.Set(signatureHeader, base64Signature(value, signatureKey))
+		handlerSpy := handlerSpy{}
+		responseSpy := responseSpy{httptest.NewRecorder()}
+		logSpy := loggerSpy{}
+
+		tenant.AddToCtx(defaultSystemBaseUri, signatureKey, logSpy.logError, tenant.WithRequireBothIdentityHeaders())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+		if err := responseSpy.assertStatusCodeIs(http.StatusBadRequest); err != nil {
+			t.Errorf("only header %v: %v", header, err)
+		}
+		if handlerSpy.hasBeenCalled {
+			t.Errorf("only header %v: inner handler should not have been called", header)
+		}
+		if err := logSpy.assertLogContains("incomplete identity"); err != nil {
+			t.Errorf("only header %v: %v", header, err)
+		}
+	}
+}
+
+func TestRequireBothIdentityHeadersAndBothHeaders_UsesHeaders(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx(defaultSystemBaseUri, signatureKey, logSpy.logError, tenant.WithRequireBothIdentityHeaders())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertBaseUriIs("https://sample.example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInitiatorHeaderPriorityWithXForwardedHostOnly_IgnoresForwardedHeader(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(forwardedHeader, "host=untrusted.example.com")
+	req.Header.Set(xForwardedHostHeader, "trusted.example.com")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithInitiatorHeaderPriority("X-Forwarded-Host"))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertInitiatorSystemBaseUriIs(uriPrefix + "trusted.example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInitiatorHeaderPriorityWithUnsupportedHeader_Returns500(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithInitiatorHeaderPriority("X-Real-Host"))(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusInternalServerError); err != nil {
+		t.Error(err)
+	}
+	if err := logSpy.assertLogContains("X-Real-Host"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInvalidOptionAndNilLogger_Returns500(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithInitiatorHeaderPriority("X-Real-Host"))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusInternalServerError); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+}
+
+func TestClock_VerifiedAtIsTakenFromClock(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	var verifiedAt time.Time
+	var ok bool
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		verifiedAt, ok = tenant.VerifiedAtFromCtx(r.Context())
+	})
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithClock(func() time.Time { return now }))(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	if !ok {
+		t.Fatal("expected verification time on context")
+	}
+	if !verifiedAt.Equal(now) {
+		t.Errorf("got wrong verification time from context: got %v want %v", verifiedAt, now)
+	}
+}
+
+func TestSuccessLogger_LogsValidRequest(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	logSpy := loggerSpy{}
+	successLogSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithSuccessLogger(successLogSpy.logError))(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := successLogSpy.assertLogContains("a12be5"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSuccessLogger_DoesntLogRejectedRequest(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(tenantIdHeader, "other")
+	logSpy := loggerSpy{}
+	successLogSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithSuccessLogger(successLogSpy.logError))(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), req)
+
+	if successLogSpy.hasBeenCalled {
+		t.Error("success logger should not have been called")
+	}
+}
+
+func TestEmptyTenantIdHeader_Returns400(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(tenantIdHeader, "")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx(defaultSystemBaseUri, signatureKey, logSpy.logError)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+	if err := logSpy.assertLogContains("empty tenant id"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEmptyTenantIdHeaderAndEmptyTenantIdAsDefault_UsesTenantIdZero(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(tenantIdHeader, "")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx(defaultSystemBaseUri, signatureKey, logSpy.logError, tenant.WithEmptyTenantIdAsDefault())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("0"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHeadersAndNoSignatureSecretKeyAndMissingKeyStatus_ReturnsConfiguredStatus(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", nil, logSpy.logError, tenant.WithMissingKeyStatus(http.StatusServiceUnavailable))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusServiceUnavailable); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+	if err := logSpy.assertLogContains("secret"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHeadersAndNoSignatureSecretKeyAndMissingKeyHandler_CallsFallbackHandler(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	fallbackSpy := handlerSpy{}
+	handlerSpy := handlerSpy{}
+	var verified bool
+	fallback := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		verified = tenant.SignatureVerifiedFromCtx(r.Context())
+		fallbackSpy.ServeHTTP(rw, r)
+	})
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", nil, logSpy.logError, tenant.WithMissingKeyHandler(fallback))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+	if err := fallbackSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+	if err := fallbackSpy.assertBaseUriIs("https://sample.example.com"); err != nil {
+		t.Error(err)
+	}
+	if verified {
+		t.Error("tenant information passed to the fallback handler must not be verified")
+	}
+	if err := logSpy.assertLogContains("secret"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInvalidSignatureAndMissingKeyHandler_Returns403(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(tenantIdHeader, "b34cd6")
+	fallbackSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithMissingKeyHandler(&fallbackSpy))(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if fallbackSpy.hasBeenCalled {
+		t.Error("fallback handler should not have been called")
+	}
+}
+
+func TestUnsignedHeadersAndTestModeRequireHeaders_CallsInnerHandler(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/myresource/sub", nil)
+	req.Header.Set(systemBaseUriHeader, "https://sample.example.com")
+	req.Header.Set(tenantIdHeader, "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", nil, logSpy.logError, tenant.WithTestModeRequireHeaders())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertBaseUriIs("https://sample.example.com"); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+	if err := logSpy.assertLogContains("test mode"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMissingTenantIdHeaderAndTestModeRequireHeaders_Returns400(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/myresource/sub", nil)
+	req.Header.Set(systemBaseUriHeader, "https://sample.example.com")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", nil, nil, tenant.WithTestModeRequireHeaders())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+}
+
+func TestMessyForwardedHeadersAndNormalizeForwarded_PassesNormalizedHeaderDownstream(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(forwardedHeader, ` for=192.0.2.60; HOST="Initiator.example.com/";proto=http, host=proxy.example.com`)
+	req.Header.Set(xForwardedHostHeader, "other.example.com, proxy.example.com")
+	var downstreamHeader http.Header
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		downstreamHeader = r.Header
+	})
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithNormalizeForwarded())(next).ServeHTTP(httptest.NewRecorder(), req)
+
+	if downstreamHeader == nil {
+		t.Fatal("inner handler should have been called")
+	}
+	if got, want := downstreamHeader.Get(forwardedHeader), `host="Initiator.example.com";proto=https`; got != want {
+		t.Errorf("got wrong Forwarded header downstream: got %v want %v", got, want)
+	}
+	if got := downstreamHeader.Get(xForwardedHostHeader); got != "" {
+		t.Errorf("X-Forwarded-Host should have been removed but is %v", got)
+	}
+	if got := req.Header.Get(xForwardedHostHeader); got == "" {
+		t.Error("header of the incoming request should not have been changed")
+	}
+}
+
+func TestBaseUriWithoutSchemeAndAssumeHTTPS_StoresBaseUriWithHttpsScheme(t *testing.T) {
+	req := signedRequest(t, "sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithAssumeHTTPS())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertBaseUriIs("https://sample.example.com"); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertInitiatorSystemBaseUriIs("https://sample.example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBaseUriWithoutSchemeAndAssumeHTTPS_VerifiesSignatureOverRawValue(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(systemBaseUriHeader, "sample.example.com")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithAssumeHTTPS())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBaseUriWithSchemeAndAssumeHTTPS_StoresBaseUriUnchanged(t *testing.T) {
+	req := signedRequest(t, "http://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithAssumeHTTPS())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := handlerSpy.assertBaseUriIs("http://sample.example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidForwardedHostAndValidateForwardedHost_UsesForwardedHostAsInitiator(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(xForwardedHostHeader, "initiator.example.com:8443")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithValidateForwardedHost())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := handlerSpy.assertInitiatorSystemBaseUriIs("https://initiator.example.com:8443"); err != nil {
+		t.Error(err)
+	}
+	if logSpy.hasBeenCalled {
+		t.Errorf("unexpected log '%v'", logSpy.lastMessage)
+	}
+}
+
+func TestInvalidForwardedHostAndValidateForwardedHost_DropsForwardedHost(t *testing.T) {
+	for _, host := range []string{"bad host.example.com", "initiator.example.com/path", "user@initiator.example.com", "<script>", "initiator.example.com?x=1"} {
+		req := signedRequest(t, "https://sample.example.com", "a12be5")
+		req.Header.Set(xForwardedHostHeader, host)
+		handlerSpy := handlerSpy{}
+		responseSpy := responseSpy{httptest.NewRecorder()}
+		logSpy := loggerSpy{}
+
+		tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithValidateForwardedHost())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+		if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+			t.Error(err)
+		}
+		if err := handlerSpy.assertInitiatorSystemBaseUriIs("https://sample.example.com"); err != nil {
+			t.Errorf("host %v: %v", host, err)
+		}
+		if err := logSpy.assertLogContains("invalid forwarded host"); err != nil {
+			t.Errorf("host %v: %v", host, err)
+		}
+	}
+}
+
+func TestForwardedHeadersAndNormalizeForwarded_RawValuesAreAvailableDownstream(t *testing.T) {
+	const forwarded = `for=192.0.2.60;host="initiator.example.com";proto=http`
+	const xForwardedHost = "other.example.com, proxy.example.com"
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(forwardedHeader, forwarded)
+	req.Header.Set(xForwardedHostHeader, xForwardedHost)
+	var rawForwarded, rawXForwardedHost string
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rawForwarded, rawXForwardedHost = tenant.RawForwardedFromCtx(r.Context())
+	})
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithNormalizeForwarded())(next).ServeHTTP(httptest.NewRecorder(), req)
+
+	if rawForwarded != forwarded {
+		t.Errorf("got wrong raw Forwarded value: got %v want %v", rawForwarded, forwarded)
+	}
+	if rawXForwardedHost != xForwardedHost {
+		t.Errorf("got wrong raw X-Forwarded-Host value: got %v want %v", rawXForwardedHost, xForwardedHost)
+	}
+}
+
+func TestV1SignatureAndMinSignatureVersion2_Returns403AndLogs(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithSignatureVersions(1, 2), tenant.WithMinSignatureVersion(2))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+	if err := logSpy.assertLogContains("signature version"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestV2SignatureAndMinSignatureVersion2_CallsInnerHandler(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Del(signatureHeader)
+	req.Header.Set("x-dv-sig-2", base64Signature("https://sample.example.com\na12be5", signatureKey))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignatureVersions(1, 2), tenant.WithMinSignatureVersion(2))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSignatureVerifiedFromCtx(t *testing.T) {
+	unsignedRequest := func() *http.Request {
+		req, _ := http.NewRequest("GET", "/myresource/sub", nil)
+		req.Header.Set(systemBaseUriHeader, "https://sample.example.com")
+		req.Header.Set(tenantIdHeader, "a12be5")
+		return req
+	}
+	noHeadersRequest, _ := http.NewRequest("GET", "/myresource/sub", nil)
+	testCases := []struct {
+		name     string
+		req      *http.Request
+		key      []byte
+		options  []tenant.Option
+		verified bool
+	}{
+		{"verified", signedRequest(t, "https://sample.example.com", "a12be5"), signatureKey, nil, true},
+		{"test mode", unsignedRequest(), nil, []tenant.Option{tenant.WithTestModeRequireHeaders()}, false},
+		{"insecure", noHeadersRequest, nil, nil, false},
+	}
+	for _, tc := range testCases {
+		var verified, called bool
+		next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			called = true
+			verified = tenant.SignatureVerifiedFromCtx(r.Context())
+		})
+
+		tenant.AddToCtx("https://default.example.com", tc.key, nil, tc.options...)(next).ServeHTTP(httptest.NewRecorder(), tc.req)
+
+		if !called {
+			t.Errorf("%v: inner handler should have been called", tc.name)
+		}
+		if verified != tc.verified {
+			t.Errorf("%v: got wrong signature verified flag: got %v want %v", tc.name, verified, tc.verified)
+		}
+	}
+}
+
+func TestHostRewrittenByGatewayAndAllowedHostsMatchingBaseUri_CallsInnerHandler(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Host = "internal-service.cluster.local:8080"
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithAllowedHosts("SAMPLE.example.com:443"))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertBaseUriIs("https://sample.example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHostMatchingButBaseUriNotAllowed_AllowedHosts_Returns403(t *testing.T) {
+	req := signedRequest(t, "https://other.example.com", "a12be5")
+	req.Host = "sample.example.com"
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithAllowedHosts("sample.example.com"))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+}
+
+func TestActiveTenant_TenantActiveCheck_CallsInnerHandler(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	var checkedTenantId string
+	isActive := func(ctx context.Context, tenantId string) (bool, error) {
+		checkedTenantId = tenantId
+		return true, nil
+	}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithTenantActiveCheck(isActive))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if checkedTenantId != "a12be5" {
+		t.Errorf("got wrong tenant id in check: got %v want %v", checkedTenantId, "a12be5")
+	}
+}
+
+func TestInactiveTenant_TenantActiveCheck_Returns403AndLogs(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+	isActive := func(ctx context.Context, tenantId string) (bool, error) {
+		return false, nil
+	}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithTenantActiveCheck(isActive))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+	if err := logSpy.assertLogContains("tenant inactive"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFailingTenantActiveCheck_Returns500(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	isActive := func(ctx context.Context, tenantId string) (bool, error) {
+		return false, errors.New("tenant registry unavailable")
+	}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithTenantActiveCheck(isActive))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusInternalServerError); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+}
+
+func TestCanceledRequest_TenantActiveCheck_ReceivesCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := signedRequest(t, "https://sample.example.com", "a12be5").WithContext(ctx)
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	isActive := func(ctx context.Context, tenantId string) (bool, error) {
+		return false, ctx.Err()
+	}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithTenantActiveCheck(isActive))(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusInternalServerError); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnsignedRequest_TenantActiveCheck_DoesntCallCheck(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	var called bool
+	isActive := func(ctx context.Context, tenantId string) (bool, error) {
+		called = true
+		return false, nil
+	}
+
+	tenant.AddToCtx("https://default.example.com", signatureKey, nil, tenant.WithTenantActiveCheck(isActive))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if called {
+		t.Error("tenant active check should not have been called for unverified tenant")
+	}
+}
+
+func TestHttpBaseUriAndForceSchemeHttps_StoresBaseUriWithHttpsAndLogsWarning(t *testing.T) {
+	req := signedRequest(t, "http://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithForceScheme("https"))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertBaseUriIs("https://sample.example.com"); err != nil {
+		t.Error(err)
+	}
+	if err := logSpy.assertLogContains("WARNING"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHttpsBaseUriAndForceSchemeHttps_DoesntLogWarning(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithForceScheme("https"))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := handlerSpy.assertBaseUriIs("https://sample.example.com"); err != nil {
+		t.Error(err)
+	}
+	if logSpy.hasBeenCalled {
+		t.Errorf("unexpected log '%v'", logSpy.lastMessage)
+	}
+}
+
+func TestInvalidSignatureOfEnforcedTenant_EnforcementPolicy_Returns403(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(signatureHeader, base64Signature("https://sample.example.coma12be5", []byte("other")))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	var policyTenantId string
+	policy := func(tenantId string) bool {
+		policyTenantId = tenantId
+		return tenantId == "a12be5"
+	}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithEnforcementPolicy(policy))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+	if policyTenantId != "a12be5" {
+		t.Errorf("policy has been called with wrong tenant id: got %v want %v", policyTenantId, "a12be5")
+	}
+}
+
+func TestInvalidSignatureOfAuditedTenant_EnforcementPolicy_PassesRequestAndLogsWarning(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(signatureHeader, base64Signature("https://sample.example.coma12be5", []byte("other")))
+	var signatureVerified bool
+	var tenantId string
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		signatureVerified = tenant.SignatureVerifiedFromCtx(r.Context())
+		tenantId, _ = tenant.IdFromCtx(r.Context())
+	})
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+	policy := func(tenantId string) bool { return tenantId != "a12be5" }
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithEnforcementPolicy(policy))(next).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if tenantId != "a12be5" {
+		t.Errorf("got wrong tenant id: got %v want %v", tenantId, "a12be5")
+	}
+	if signatureVerified {
+		t.Error("signature of audited tenant should not be reported as verified")
+	}
+	if err := logSpy.assertLogContains("audited"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFailureOfRedactedTenant_TenantLogRedaction_LogsHashInsteadOfTenantId(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(signatureHeader, base64Signature("https://sample.example.coma12be5", []byte("other")))
+	logSpy := loggerSpy{}
+	redact := func(tenantId string) bool { return tenantId == "a12be5" }
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithTenantLogRedaction(redact))(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := logSpy.assertLogContains("sha256:"); err != nil {
+		t.Error(err)
+	}
+	if strings.Contains(logSpy.lastMessage, "a12be5") {
+		t.Errorf("log contains redacted tenant id: %v", logSpy.lastMessage)
+	}
+}
+
+func TestRedactedTenant_TenantLogRedaction_KeepsTenantIdInContext(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	logSpy := loggerSpy{}
+	redact := func(tenantId string) bool { return true }
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithTenantLogRedaction(redact), tenant.WithSuccessLogger(logSpy.logError))(&handlerSpy).ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+	if err := logSpy.assertLogContains("sha256:"); err != nil {
+		t.Error(err)
+	}
+	if strings.Contains(logSpy.lastMessage, "a12be5") {
+		t.Errorf("log contains redacted tenant id: %v", logSpy.lastMessage)
+	}
+}
+
+func TestShortRedactedTenantId_TenantLogRedaction_KeepsRestOfMessage(t *testing.T) {
+	req := signedRequest(t, "https://sample1.example.com", "1")
+	logSpy := loggerSpy{}
+	redact := func(tenantId string) bool { return true }
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithTenantLogRedaction(redact), tenant.WithSuccessLogger(logSpy.logError))(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := logSpy.assertLogContains("SystemBaseUri 'https://sample1.example.com'"); err != nil {
+		t.Error(err)
+	}
+	if strings.Contains(logSpy.lastMessage, "TenantId '1'") {
+		t.Errorf("log contains redacted tenant id: %v", logSpy.lastMessage)
+	}
+}
+
+func TestInvalidCookieOfRedactedTenant_TenantLogRedaction_LogsHashInsteadOfTenantId(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.AddCookie(&http.Cookie{Name: "dv-tenant", Value: tenant.SignedCookieValue("https://sample.example.com", "a12be5", cookieExpiry, []byte("other"))})
+	logSpy := loggerSpy{}
+	redact := func(tenantId string) bool { return tenantId == "a12be5" }
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithCookieSource("dv-tenant"), tenant.WithTenantLogRedaction(redact))(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := logSpy.assertLogContains("sha256:"); err != nil {
+		t.Error(err)
+	}
+	if strings.Contains(logSpy.lastMessage, "a12be5") {
+		t.Errorf("log contains redacted tenant id: %v", logSpy.lastMessage)
+	}
+}
+
+func TestNotRedactedTenant_TenantLogRedaction_LogsTenantId(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(signatureHeader, base64Signature("https://sample.example.coma12be5", []byte("other")))
+	logSpy := loggerSpy{}
+	redact := func(tenantId string) bool { return false }
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithTenantLogRedaction(redact))(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := logSpy.assertLogContains("a12be5"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHexSignature_HexSignatureEncoding(t *testing.T) {
+	signature, err := base64.StdEncoding.DecodeString(base64Signature("https://sample.example.coma12be5", signatureKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := map[string]struct {
+		signature string
+		want      int
+	}{
+		"uppercase hex": {strings.ToUpper(hex.EncodeToString(signature)), http.StatusOK},
+		"lowercase hex": {hex.EncodeToString(signature), http.StatusOK},
+		"no hex":        {base64.StdEncoding.EncodeToString(signature), http.StatusForbidden},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := signedRequest(t, "https://sample.example.com", "a12be5")
+			req.Header.Set(signatureHeader, tc.signature)
+			handlerSpy := handlerSpy{}
+			responseSpy := responseSpy{httptest.NewRecorder()}
+
+			tenant.AddToCtx("", signatureKey, nil, tenant.WithHexSignatureEncoding())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+			if err := responseSpy.assertStatusCodeIs(tc.want); err != nil {
+				t.Error(err)
+			}
+			if handlerSpy.hasBeenCalled != (tc.want == http.StatusOK) {
+				t.Errorf("got wrong call of inner handler: got %v", handlerSpy.hasBeenCalled)
+			}
+		})
+	}
+}
+
+func TestSignRequest_HexSignatureEncoding_SetsHexSignature(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	signature, err := base64.StdEncoding.DecodeString(req.Header.Get(signatureHeader))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tenant.SignRequest(req, signatureKey, tenant.WithHexSignatureEncoding()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := req.Header.Get(signatureHeader); got != hex.EncodeToString(signature) {
+		t.Errorf("got wrong signature: got %v want %v", got, hex.EncodeToString(signature))
+	}
+}
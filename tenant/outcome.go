@@ -0,0 +1,50 @@
+package tenant
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Outcome describes the result of the tenant verification of a single request for live monitoring.
+type Outcome struct {
+	// TenantId is the tenant id of an accepted request or the tenant id claimed by a rejected request.
+	TenantId string
+	// Accepted reports whether the request has been passed to the next handler.
+	Accepted bool
+	// Reason describes why the request has been rejected.
+	Reason string
+	// Time is the time of the verification according to the clock configured by WithClock.
+	Time time.Time
+}
+
+// WithOutcomeChannel sends an Outcome for every request which passes the middleware to the channel.
+// The outcome is sent without blocking the request, i.e. it is dropped if the buffer of the channel is full
+// or there is no receiver. Use a buffered channel and Verifier.DroppedOutcomes to monitor dropped outcomes.
+func WithOutcomeChannel(outcomes chan<- Outcome) Option {
+	return func(v *Verifier) error {
+		if outcomes == nil {
+			return errors.New("outcome channel must not be nil")
+		}
+		v.outcomes = outcomes
+		return nil
+	}
+}
+
+// DroppedOutcomes returns the number of outcomes which have been dropped because the channel configured by
+// WithOutcomeChannel was full.
+func (v *Verifier) DroppedOutcomes() int64 {
+	return atomic.LoadInt64(&v.droppedOutcomes)
+}
+
+// sendOutcome sends the outcome to the channel configured by WithOutcomeChannel without blocking.
+func (v *Verifier) sendOutcome(tenantId string, accepted bool, reason string) {
+	if v.outcomes == nil {
+		return
+	}
+	select {
+	case v.outcomes <- Outcome{TenantId: tenantId, Accepted: accepted, Reason: reason, Time: v.now()}:
+	default:
+		atomic.AddInt64(&v.droppedOutcomes, 1)
+	}
+}
@@ -0,0 +1,54 @@
+package tenant_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestRequests_OutcomeChannel_SendsOutcomes(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	outcomes := make(chan tenant.Outcome, 2)
+	v, err := tenant.NewVerifier(tenant.WithSignatureSecretKeys(signatureKey), tenant.WithOutcomeChannel(outcomes), tenant.WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := signedRequest(t, "https://sample.example.com", "a12be5")
+	tampered.Header.Set(tenantIdHeader, "other")
+
+	v.Middleware(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), signedRequest(t, "https://sample.example.com", "a12be5"))
+	v.Middleware(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), tampered)
+
+	accepted := <-outcomes
+	if accepted.TenantId != "a12be5" || !accepted.Accepted || accepted.Reason != "" || !accepted.Time.Equal(now) {
+		t.Errorf("got wrong outcome for accepted request: %+v", accepted)
+	}
+	rejected := <-outcomes
+	if rejected.TenantId != "other" || rejected.Accepted || rejected.Reason == "" || !rejected.Time.Equal(now) {
+		t.Errorf("got wrong outcome for rejected request: %+v", rejected)
+	}
+	if dropped := v.DroppedOutcomes(); dropped != 0 {
+		t.Errorf("got wrong number of dropped outcomes: got %v want %v", dropped, 0)
+	}
+}
+
+func TestFullChannel_OutcomeChannel_DropsOutcome(t *testing.T) {
+	outcomes := make(chan tenant.Outcome, 1)
+	v, err := tenant.NewVerifier(tenant.WithSignatureSecretKeys(signatureKey), tenant.WithOutcomeChannel(outcomes))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		v.Middleware(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), signedRequest(t, "https://sample.example.com", "a12be5"))
+	}
+
+	if dropped := v.DroppedOutcomes(); dropped != 2 {
+		t.Errorf("got wrong number of dropped outcomes: got %v want %v", dropped, 2)
+	}
+	if len(outcomes) != 1 {
+		t.Errorf("got wrong number of buffered outcomes: got %v want %v", len(outcomes), 1)
+	}
+}
@@ -0,0 +1,39 @@
+package tenant
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// pemKeyBlockType is the type of the PEM block which contains a signature secret key.
+const pemKeyBlockType = "SIGNATURE SECRET KEY"
+
+// KeyFromPEM returns the signature secret key contained in a PEM block of type "SIGNATURE SECRET KEY", e.g.
+//
+//	-----BEGIN SIGNATURE SECRET KEY-----
+//	EduQ0b0BskmLLxXsjjhH9Su8ozTvZl6Z/5/HlaORoRg=
+//	-----END SIGNATURE SECRET KEY-----
+//
+// The key can be passed to AddToCtx or WithSignatureSecretKeys. An error is returned if pemBytes doesn't contain
+// exactly one such block, if the block has headers, e.g. because it is encrypted, or if the key is empty.
+func KeyFromPEM(pemBytes []byte) ([]byte, error) {
+	block, rest := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("decoding signature secret key because no PEM block has been found")
+	}
+	if block.Type != pemKeyBlockType {
+		return nil, fmt.Errorf("decoding signature secret key because PEM block has type '%v' but '%v' is required", block.Type, pemKeyBlockType)
+	}
+	if len(block.Headers) > 0 {
+		return nil, errors.New("decoding signature secret key because PEM block has headers")
+	}
+	if len(bytes.TrimSpace(rest)) > 0 {
+		return nil, errors.New("decoding signature secret key because data follows the PEM block")
+	}
+	if len(block.Bytes) == 0 {
+		return nil, errors.New("decoding signature secret key because PEM block is empty")
+	}
+	return block.Bytes, nil
+}
@@ -0,0 +1,52 @@
+package tenant_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+const pemKey = `-----BEGIN SIGNATURE SECRET KEY-----
+EduQ0b0BskmLLxXsjjhH9Su8ozTvZl6Z/5/HlaORoRg=
+-----END SIGNATURE SECRET KEY-----
+`
+
+func TestValidPEM_KeyFromPEM_ReturnsKey(t *testing.T) {
+	key, err := tenant.KeyFromPEM([]byte(pemKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(key, rotatedSignatureKey) {
+		t.Errorf("got wrong key: got %v want %v", key, rotatedSignatureKey)
+	}
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(signatureHeader, base64Signature("https://sample.example.coma12be5", rotatedSignatureKey))
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	tenant.AddToCtx("", key, nil)(&handlerSpy{}).ServeHTTP(responseSpy, req)
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMalformedPEM_KeyFromPEM_ReturnsError(t *testing.T) {
+	testCases := map[string]string{
+		"no PEM block":     "EduQ0b0BskmLLxXsjjhH9Su8ozTvZl6Z/5/HlaORoRg=",
+		"truncated block":  strings.TrimSuffix(pemKey, "-----END SIGNATURE SECRET KEY-----\n"),
+		"wrong block type": strings.Replace(pemKey, "SIGNATURE SECRET KEY", "PRIVATE KEY", 2),
+		"empty block":      "-----BEGIN SIGNATURE SECRET KEY-----\n-----END SIGNATURE SECRET KEY-----\n",
+		"encrypted block":  strings.Replace(pemKey, "KEY-----\n", "KEY-----\nProc-Type: 4,ENCRYPTED\n\n", 1),
+		"additional block": pemKey + pemKey,
+	}
+	for name, pemBytes := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := tenant.KeyFromPEM([]byte(pemBytes)); err == nil {
+				t.Error("expected an error for malformed PEM data")
+			}
+		})
+	}
+}
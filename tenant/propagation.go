@@ -0,0 +1,48 @@
+package tenant
+
+import (
+	"context"
+)
+
+// Keys of the map returned by PropagationMap.
+const (
+	tenantIdPropagationKey               = tenantIdBaggageKey
+	systemBaseUriPropagationKey          = "dv.tenant.baseuri"
+	initiatorSystemBaseUriPropagationKey = "dv.tenant.initiator"
+)
+
+// PropagationMap returns the tenant information of ctx as map for propagators which carry values across process
+// boundaries, e.g. {"dv.tenant.id": "a12be5", "dv.tenant.baseuri": "https://sample.example.com",
+// "dv.tenant.initiator": "https://sample.example.com"}. Values which are not on the context are omitted.
+// The map never contains signatures or keys. Use CtxFromPropagationMap to restore the tenant information.
+func PropagationMap(ctx context.Context) map[string]string {
+	m := make(map[string]string, 3)
+	if tenantId, err := IdFromCtx(ctx); err == nil && tenantId != "" {
+		m[tenantIdPropagationKey] = tenantId
+	}
+	if systemBaseUri, err := SystemBaseUriFromCtx(ctx); err == nil && systemBaseUri != "" {
+		m[systemBaseUriPropagationKey] = systemBaseUri
+	}
+	if initiatorSystemBaseUri, err := InitiatorSystemBaseUriFromCtx(ctx); err == nil && initiatorSystemBaseUri != "" {
+		m[initiatorSystemBaseUriPropagationKey] = initiatorSystemBaseUri
+	}
+	return m
+}
+
+// CtxFromPropagationMap returns a new context with the tenant information of m which has been created by
+// PropagationMap. Values which are missing in m are not set.
+//
+// The map isn't signed, so the context reports the tenant information as not verified by a signature
+// (cf. SignatureVerifiedFromCtx) even if ctx has been verified. Use OutgoingHeaders and CtxFromMap if the receiver can't trust the sender.
+func CtxFromPropagationMap(ctx context.Context, m map[string]string) context.Context {
+	if tenantId := m[tenantIdPropagationKey]; tenantId != "" {
+		ctx = SetId(ctx, tenantId)
+	}
+	if systemBaseUri := m[systemBaseUriPropagationKey]; systemBaseUri != "" {
+		ctx = SetSystemBaseUri(ctx, systemBaseUri)
+	}
+	if initiatorSystemBaseUri := m[initiatorSystemBaseUriPropagationKey]; initiatorSystemBaseUri != "" {
+		ctx = SetInitiatorSystemBaseUri(ctx, initiatorSystemBaseUri)
+	}
+	return context.WithValue(ctx, signatureVerifiedCtxKey, false)
+}
@@ -0,0 +1,58 @@
+package tenant_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestPropagationMap_RoundTrip(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(forwardedHeader, "host=initiator.example.com")
+	var m map[string]string
+	capture := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) { m = tenant.PropagationMap(r.Context()) })
+	tenant.AddToCtx("", signatureKey, nil)(capture).ServeHTTP(httptest.NewRecorder(), req)
+
+	ctx := tenant.CtxFromPropagationMap(context.Background(), m)
+
+	want := map[string]string{
+		"dv.tenant.id":        "a12be5",
+		"dv.tenant.baseuri":   "https://sample.example.com",
+		"dv.tenant.initiator": "https://initiator.example.com",
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("got wrong propagation map: got %v want %v", m, want)
+	}
+	got := tenant.InfoFromCtx(ctx)
+	if wantInfo := (tenant.TenantInfo{TenantId: "a12be5", SystemBaseUri: "https://sample.example.com", InitiatorSystemBaseUri: "https://initiator.example.com"}); got != wantInfo {
+		t.Errorf("got wrong tenant info: got %+v want %+v", got, wantInfo)
+	}
+}
+
+func TestEmptyContext_PropagationMap_ReturnsEmptyMap(t *testing.T) {
+	m := tenant.PropagationMap(context.Background())
+
+	if len(m) != 0 {
+		t.Errorf("expected empty map but got %v", m)
+	}
+	if _, err := tenant.IdFromCtx(tenant.CtxFromPropagationMap(context.Background(), m)); err == nil {
+		t.Error("expected no tenant id on context")
+	}
+}
+
+func TestVerifiedContext_CtxFromPropagationMap_IsNotVerified(t *testing.T) {
+	var verified context.Context
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	capture := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) { verified = r.Context() })
+	tenant.AddToCtx("", signatureKey, nil)(capture).ServeHTTP(httptest.NewRecorder(), req)
+
+	ctx := tenant.CtxFromPropagationMap(verified, map[string]string{"dv.tenant.id": "b34cd6"})
+
+	if tenant.SignatureVerifiedFromCtx(ctx) {
+		t.Error("expected restored tenant information not to be verified")
+	}
+}
@@ -0,0 +1,42 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// CtxFromMap verifies the tenant headers contained in m and returns a new context containing the
+// tenant information. It is meant for consumers which receive the tenant headers as part of a
+// message instead of a http request, e.g. from a queue.
+//
+// The keys of m are the header names as returned by OutgoingHeaders (case-insensitive). The headers
+// are verified exactly like AddToCtx does for http requests with the difference that there is no
+// default systemBaseUri and no tenant "0". An error is returned if the headers are not valid or if m doesn't
+// contain both signed headers x-dv-baseuri and x-dv-tenant-id, so a message without tenant is never processed as tenant "0".
+func CtxFromMap(ctx context.Context, m map[string]string, signatureSecretKey []byte) (context.Context, error) {
+	header := http.Header{}
+	for name, value := range m {
+		header.Set(name, value)
+	}
+	if headerValue(header, systemBaseUriHeader) == "" || headerValue(header, tenantIdHeader) == "" {
+		return nil, fmt.Errorf("map doesn't contain both tenant headers '%v' and '%v'", systemBaseUriHeader, tenantIdHeader)
+	}
+	var signatureSecretKeys [][]byte
+	if signatureSecretKey != nil {
+		signatureSecretKeys = [][]byte{signatureSecretKey}
+	}
+	v, err := newVerifier("", signatureSecretKeys, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	info, _, _, vErr := v.validate((&http.Request{Header: header}).WithContext(ctx))
+	if vErr != nil {
+		return nil, vErr
+	}
+	if !info.SignatureVerified {
+		return nil, errors.New("tenant headers in map have not been verified by a signature")
+	}
+	return newCtx(ctx, info), nil
+}
@@ -0,0 +1,78 @@
+package tenant_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestOutgoingHeadersAsMap_CtxFromMap_ReturnsContextWithTenant(t *testing.T) {
+	ctx := tenant.SetSystemBaseUri(context.Background(), "https://sample.example.com")
+	ctx = tenant.SetId(ctx, "a12be5")
+	header, err := tenant.OutgoingHeaders(ctx, signatureKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := map[string]string{}
+	for name := range header {
+		m[name] = header.Get(name)
+	}
+
+	consumerCtx, err := tenant.CtxFromMap(context.Background(), m, signatureKey)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := tenant.IdFromCtx(consumerCtx); id != "a12be5" {
+		t.Errorf("got wrong tenantId from context: got %v want %v", id, "a12be5")
+	}
+	if u, _ := tenant.SystemBaseUriFromCtx(consumerCtx); u != "https://sample.example.com" {
+		t.Errorf("got wrong systemBaseUri from context: got %v want %v", u, "https://sample.example.com")
+	}
+}
+
+func TestTamperedMap_CtxFromMap_ReturnsError(t *testing.T) {
+	m := map[string]string{
+		systemBaseUriHeader: "https://sample.example.com",
+		tenantIdHeader:      "other",
+		signatureHeader:     base64Signature("https://sample.example.com"+"a12be5", signatureKey),
+	}
+
+	if _, err := tenant.CtxFromMap(context.Background(), m, signatureKey); err == nil {
+		t.Error("expected error for tampered map")
+	}
+}
+
+func TestMapWithoutTenantHeaders_CtxFromMap_ReturnsError(t *testing.T) {
+	for name, m := range map[string]map[string]string{
+		"empty map":          {},
+		"only signature":     {signatureHeader: base64Signature("", signatureKey)},
+		"only other headers": {"content-type": "application/json"},
+		"without tenant id": {
+			systemBaseUriHeader: "https://sample.example.com",
+			signatureHeader:     base64Signature("https://sample.example.com", signatureKey),
+		},
+		"without systemBaseUri": {
+			tenantIdHeader:  "a12be5",
+			signatureHeader: base64Signature("a12be5", signatureKey),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := tenant.CtxFromMap(context.Background(), m, signatureKey); err == nil {
+				t.Error("expected error for map without tenant headers")
+			}
+		})
+	}
+}
+
+func TestMapWithoutSignature_CtxFromMap_ReturnsError(t *testing.T) {
+	m := map[string]string{
+		systemBaseUriHeader: "https://sample.example.com",
+		tenantIdHeader:      "a12be5",
+	}
+
+	if _, err := tenant.CtxFromMap(context.Background(), m, signatureKey); err == nil {
+		t.Error("expected error for map without signature")
+	}
+}
@@ -0,0 +1,30 @@
+package tenant
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	serverTimingHeader = "Server-Timing"
+	serverTimingMetric = "tenant-verify"
+)
+
+// WithServerTiming adds the duration of the verification as metric tenant-verify of the Server-Timing header
+// of the response, e.g. Server-Timing: tenant-verify;dur=0.213. The duration is given in milliseconds.
+// Metrics which have already been set, e.g. by an outer middleware, are preserved.
+func WithServerTiming() Option {
+	return func(v *Verifier) error {
+		v.serverTiming = true
+		return nil
+	}
+}
+
+// addServerTiming appends the verification duration to the Server-Timing header of the response.
+func addServerTiming(header http.Header, duration time.Duration) {
+	ms := strconv.FormatFloat(float64(duration)/float64(time.Millisecond), 'f', 3, 64)
+	metrics := append(header[http.CanonicalHeaderKey(serverTimingHeader)], serverTimingMetric+";dur="+ms)
+	header.Set(serverTimingHeader, strings.Join(metrics, ", "))
+}
@@ -0,0 +1,65 @@
+package tenant_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+var tenantVerifyTiming = regexp.MustCompile(`(^|, )tenant-verify;dur=[0-9]+(\.[0-9]+)?($|, )`)
+
+func TestServerTiming_AddsVerificationDuration(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	rec := httptest.NewRecorder()
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithServerTiming())(&handlerSpy{}).ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Server-Timing"); !tenantVerifyTiming.MatchString(got) {
+		t.Errorf("got wrong Server-Timing header: got %v want tenant-verify;dur=<ms>", got)
+	}
+}
+
+func TestServerTimingAndExistingMetrics_PreservesExistingMetrics(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Server-Timing", "cache;desc=\"Cache Read\";dur=23.2")
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithServerTiming())(&handlerSpy{}).ServeHTTP(rec, req)
+
+	got := rec.Header().Get("Server-Timing")
+	if !regexp.MustCompile(`^cache;desc="Cache Read";dur=23.2, `).MatchString(got) {
+		t.Errorf("existing metric has been overwritten: got %v", got)
+	}
+	if !tenantVerifyTiming.MatchString(got) {
+		t.Errorf("got wrong Server-Timing header: got %v want tenant-verify;dur=<ms>", got)
+	}
+}
+
+func TestServerTimingAndRejectedRequest_AddsVerificationDuration(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(tenantIdHeader, "other")
+	rec := httptest.NewRecorder()
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithServerTiming())(&handlerSpy{}).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("got wrong status code: got %v want %v", rec.Code, http.StatusForbidden)
+	}
+	if got := rec.Header().Get("Server-Timing"); !tenantVerifyTiming.MatchString(got) {
+		t.Errorf("got wrong Server-Timing header: got %v want tenant-verify;dur=<ms>", got)
+	}
+}
+
+func TestNoServerTiming_DoesntAddServerTimingHeader(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	rec := httptest.NewRecorder()
+
+	tenant.AddToCtx("", signatureKey, nil)(&handlerSpy{}).ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Server-Timing"); got != "" {
+		t.Errorf("expected no Server-Timing header but got %v", got)
+	}
+}
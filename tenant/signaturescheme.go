@@ -0,0 +1,59 @@
+package tenant
+
+import (
+	"context"
+	"crypto/ed25519"
+	"net/http"
+)
+
+// SignatureScheme is an interface representing the ability to verify signatures
+type SignatureScheme interface {
+	// Verify reports whether signature is a valid signature of message.
+	Verify(message, signature []byte) bool
+}
+
+// HMACScheme returns the default SignatureScheme which accepts HMAC-SHA256 signatures
+// created with any of the given keys.
+func HMACScheme(keys ...[]byte) SignatureScheme {
+	return hmacScheme{keys}
+}
+
+type hmacScheme struct {
+	keys [][]byte
+}
+
+func (s hmacScheme) Verify(message, signature []byte) bool {
+	return signatureIsValidForAnyKey(message, signature, s.keys)
+}
+
+// Ed25519Scheme returns a SignatureScheme which accepts Ed25519 signatures which can be verified
+// with the given public key.
+//
+// In contrast to HMACScheme the App only holds the public key which can't be used to forge requests.
+func Ed25519Scheme(publicKey ed25519.PublicKey) SignatureScheme {
+	return ed25519Scheme{publicKey}
+}
+
+type ed25519Scheme struct {
+	publicKey ed25519.PublicKey
+}
+
+func (s ed25519Scheme) Verify(message, signature []byte) bool {
+	if len(s.publicKey) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(s.publicKey, message, signature)
+}
+
+// AddToCtxWithVerifier works like AddToCtx but verifies the signatures with the given SignatureScheme
+// instead of a signature secret key. The signed message is the same for all schemes.
+func AddToCtxWithVerifier(defaultSystemBaseUri string, scheme SignatureScheme, logger func(ctx context.Context, message string), options ...Option) func(http.Handler) http.Handler {
+	return addToCtx(defaultSystemBaseUri, nil, logger, append([]Option{withSignatureScheme(scheme)}, options...))
+}
+
+func withSignatureScheme(scheme SignatureScheme) Option {
+	return func(v *Verifier) error {
+		v.scheme = scheme
+		return nil
+	}
+}
@@ -0,0 +1,161 @@
+package tenant_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestEd25519Signature_IsVerifiedWithPublicKey(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const systemBaseUriFromHeader = "https://sample.example.com"
+	const tenantIdFromHeader = "a12be5"
+	req.Header.Set(systemBaseUriHeader, systemBaseUriFromHeader)
+	req.Header.Set(tenantIdHeader, tenantIdFromHeader)
+	req.Header.Set(signatureHeader, base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(systemBaseUriFromHeader+tenantIdFromHeader))))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtxWithVerifier("", tenant.Ed25519Scheme(publicKey), logSpy.logError)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs(tenantIdFromHeader); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEd25519SignatureOfOtherKey_Returns403(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const tenantIdFromHeader = "a12be5"
+	req.Header.Set(tenantIdHeader, tenantIdFromHeader)
+	req.Header.Set(signatureHeader, base64.StdEncoding.EncodeToString(ed25519.Sign(otherPrivateKey, []byte(tenantIdFromHeader))))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtxWithVerifier("", tenant.Ed25519Scheme(publicKey), logSpy.logError)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+}
+
+func TestLineWrappedEd25519Signature_IsVerified(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte("https://sample.example.coma12be5")))
+	for _, separator := range []string{"\r\n", " ", "\r\n "} {
+		req := signedRequest(t, "https://sample.example.com", "a12be5")
+		// MIME encoders wrap base64 data every 76 characters
+		req.Header.Set(signatureHeader, signature[:76]+separator+signature[76:])
+		handlerSpy := handlerSpy{}
+		responseSpy := responseSpy{httptest.NewRecorder()}
+
+		tenant.AddToCtxWithVerifier("", tenant.Ed25519Scheme(publicKey), nil)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+		if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+			t.Errorf("separator %q: %v", separator, err)
+		}
+	}
+}
+
+func TestLineWrappedCorruptSignature_Returns403(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte("https://sample.example.coma12be5")))
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(signatureHeader, signature[:76]+"\r\n!"+signature[77:])
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtxWithVerifier("", tenant.Ed25519Scheme(publicKey), nil)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHMACSchemeWithFiveKeys_AcceptsSignatureOfEachKey(t *testing.T) {
+	keys := fiveKeys()
+	scheme := tenant.HMACScheme(keys...)
+	message := []byte("https://sample.example.coma12be5")
+
+	for i, key := range keys {
+		signature, _ := base64.StdEncoding.DecodeString(base64Signature(string(message), key))
+		if !scheme.Verify(message, signature) {
+			t.Errorf("expected signature of key %v to be valid", i+1)
+		}
+	}
+	signature, _ := base64.StdEncoding.DecodeString(base64Signature(string(message), []byte("unknown key")))
+	if scheme.Verify(message, signature) {
+		t.Error("expected signature of unknown key to be invalid")
+	}
+	if scheme.Verify(message, signature[:10]) {
+		t.Error("expected truncated signature to be invalid")
+	}
+}
+
+// BenchmarkMultiKeyVerify measures the worst case of the verification with several keys, that is the
+// signature is created with the last key. The cost grows linearly with the number of keys.
+func BenchmarkMultiKeyVerify(b *testing.B) {
+	keys := fiveKeys()
+	req, _ := http.NewRequest("GET", "/myresource/sub", nil)
+	req.Header.Set(systemBaseUriHeader, "https://sample.example.com")
+	req.Header.Set(tenantIdHeader, "a12be5")
+	req.Header.Set(signatureHeader, base64Signature("https://sample.example.coma12be5", keys[len(keys)-1]))
+	handler := tenant.AddToCtxWithVerifier("", tenant.HMACScheme(keys...), nil)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	rec := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.ServeHTTP(rec, req)
+	}
+	if rec.Code != http.StatusOK {
+		b.Fatalf("got wrong status code: got %v want %v", rec.Code, http.StatusOK)
+	}
+}
+
+func fiveKeys() [][]byte {
+	keys := make([][]byte, 5)
+	for i := range keys {
+		keys[i] = make([]byte, 32)
+		if _, err := rand.Read(keys[i]); err != nil {
+			panic(err)
+		}
+	}
+	return keys
+}
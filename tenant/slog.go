@@ -0,0 +1,39 @@
+//go:build go1.21
+// +build go1.21
+
+package tenant
+
+import (
+	"errors"
+	"log/slog"
+	"reflect"
+)
+
+// WithSlogBase derives a logger from base for each accepted request which contains the attributes tenant_id
+// and base_uri, i.e. the host of the systemBaseUri, and stores it in the context under the given key.
+// So handlers can log with the tenant attributes without enriching a logger themselves:
+//
+//	type loggerKey struct{}
+//	...
+//	api.Use(tenant.AddToCtx("", signatureSecretKey, logError, tenant.WithSlogBase(slog.Default(), loggerKey{})))
+//	...
+//	logger := r.Context().Value(loggerKey{}).(*slog.Logger)
+//
+// The key must be comparable and should be of an unexported type to avoid collisions (cf. context.WithValue).
+//
+// WithSlogBase is only available if the package is built with Go 1.21 or later.
+func WithSlogBase(base *slog.Logger, contextKey interface{}) Option {
+	return func(v *Verifier) error {
+		if base == nil {
+			return errors.New("slog base logger must not be nil")
+		}
+		if contextKey == nil || !reflect.TypeOf(contextKey).Comparable() {
+			return errors.New("slog context key must be comparable and not nil")
+		}
+		v.tenantLogger = func(info TenantInfo) interface{} {
+			return base.With("tenant_id", info.TenantId, "base_uri", hostOf(info.SystemBaseUri))
+		}
+		v.tenantLoggerKey = contextKey
+		return nil
+	}
+}
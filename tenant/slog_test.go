@@ -0,0 +1,49 @@
+//go:build go1.21
+// +build go1.21
+
+package tenant_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+type loggerKey struct{}
+
+func TestAcceptedRequest_SlogBase_StoresLoggerWithTenantAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	base := slog.New(slog.NewJSONHandler(&buf, nil))
+	req := signedRequest(t, "https://sample.example.com/path", "a12be5")
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		logger, ok := r.Context().Value(loggerKey{}).(*slog.Logger)
+		if !ok {
+			t.Fatal("context doesn't contain a logger")
+		}
+		logger.Info("handled")
+	})
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSlogBase(base, loggerKey{}))(next).ServeHTTP(httptest.NewRecorder(), req)
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decoding log record '%v' because: %v", buf.String(), err)
+	}
+	if record["tenant_id"] != "a12be5" {
+		t.Errorf("got wrong tenant_id: got %v want %v", record["tenant_id"], "a12be5")
+	}
+	if record["base_uri"] != "sample.example.com" {
+		t.Errorf("got wrong base_uri: got %v want %v", record["base_uri"], "sample.example.com")
+	}
+}
+
+func TestNotComparableKey_SlogBase_ReturnsError(t *testing.T) {
+	if _, err := tenant.NewVerifier(tenant.WithSlogBase(slog.Default(), []string{"key"})); err == nil {
+		t.Error("expected an error for a key which is not comparable")
+	}
+}
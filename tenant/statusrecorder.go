@@ -0,0 +1,76 @@
+package tenant
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// statusRecorder records the status code written by the wrapped handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+}
+
+func newStatusRecorder(rw http.ResponseWriter) *statusRecorder {
+	return &statusRecorder{ResponseWriter: rw, statusCode: http.StatusOK}
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	if !sr.wroteHeader {
+		sr.statusCode = code
+		sr.wroteHeader = true
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	sr.wroteHeader = true
+	return sr.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped ResponseWriter so that http.ResponseController can access its features.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// Flush flushes the wrapped ResponseWriter if it is a http.Flusher, e.g. for server-sent events.
+func (sr *statusRecorder) Flush() {
+	sr.wroteHeader = true
+	flush(sr.ResponseWriter)
+}
+
+// Hijack hijacks the connection of the wrapped ResponseWriter if it is a http.Hijacker, e.g. for websockets.
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return hijack(sr.ResponseWriter)
+}
+
+// ReadFrom uses the io.ReaderFrom of the wrapped ResponseWriter if there is one, e.g. to send files efficiently.
+func (sr *statusRecorder) ReadFrom(r io.Reader) (int64, error) {
+	sr.wroteHeader = true
+	return readFrom(sr.ResponseWriter, r)
+}
+
+func flush(rw http.ResponseWriter) {
+	if flusher, ok := rw.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func hijack(rw http.ResponseWriter) (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hijacker.Hijack()
+}
+
+func readFrom(rw http.ResponseWriter, r io.Reader) (int64, error) {
+	if readerFrom, ok := rw.(io.ReaderFrom); ok {
+		return readerFrom.ReadFrom(r)
+	}
+	// hide the ReadFrom method of the wrapper to avoid an endless recursion of io.Copy
+	return io.Copy(struct{ io.Writer }{rw}, r)
+}
@@ -0,0 +1,109 @@
+package tenant
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CloneWithHeaders returns a deep copy of req with its context changed to ctx.
+// Additionally the tenant headers are set to the systemBaseUri and tenantId taken from ctx
+// and signed with the given signatureSecretKey. So the returned request is accepted by AddToCtx
+// of an App which uses the same signatureSecretKey.
+//
+// The signature headers x-dv-sig-n and x-dv-initiator-sig as well as the headers Forwarded and
+// X-Forwarded-Host of req are removed because they belong to the incoming request. The RequestURI
+// is cleared so that the clone can be sent with a http.Client.
+//
+// An error is returned if ctx doesn't contain a systemBaseUri or tenantId or if no signatureSecretKey is given.
+func CloneWithHeaders(ctx context.Context, req *http.Request, signatureSecretKey []byte) (*http.Request, error) {
+	header, err := OutgoingHeaders(ctx, signatureSecretKey)
+	if err != nil {
+		return nil, err
+	}
+	clone := req.Clone(ctx)
+	clone.RequestURI = ""
+	for name := range clone.Header {
+		lowerName := strings.ToLower(name)
+		if strings.HasPrefix(lowerName, signatureHeaderPrefix) || lowerName == initiatorSignatureHeader ||
+			lowerName == forwardedHeader || lowerName == xForwardedHostHeader {
+			delete(clone.Header, name)
+		}
+	}
+	for name, values := range header {
+		clone.Header[name] = values
+	}
+	return clone, nil
+}
+
+// OutgoingHeaders returns the tenant headers for the systemBaseUri and tenantId taken from ctx
+// signed with the given signatureSecretKey. The headers can be added to requests or messages
+// which are sent on behalf of the tenant.
+//
+// The signatureSecretKey is independent of the keys which verify incoming requests, e.g. the keys passed to
+// AddToCtx. Use Verifier.OutgoingHeaders to sign with the key configured by WithOutboundKey.
+//
+// An error is returned if ctx doesn't contain a systemBaseUri or tenantId or if no signatureSecretKey is given.
+func OutgoingHeaders(ctx context.Context, signatureSecretKey []byte) (http.Header, error) {
+	if len(signatureSecretKey) == 0 {
+		return nil, errors.New("signing tenant headers because secret signature key has not been configured")
+	}
+	systemBaseUri, err := SystemBaseUriFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tenantId, err := IdFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set(systemBaseUriHeader, systemBaseUri)
+	header.Set(tenantIdHeader, tenantId)
+	header.Set(signatureHeader, sign([]byte(SignedMessage(systemBaseUri, tenantId, "")), signatureSecretKey))
+	return header, nil
+}
+
+func sign(message, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest signs the tenant headers of req for a middleware which is configured with the given options,
+// e.g. WithSignMethodAndPath or WithSignatureVersions. The signature is computed for the highest accepted
+// signature version and set in the corresponding header x-dv-sig-n.
+//
+// The request is always signed with the given signatureSecretKey even if the options configure other keys,
+// e.g. WithSignatureSecretKeys. So inbound and outbound requests may use different keys.
+//
+// The headers x-dv-baseuri and x-dv-tenant-id must already be set, e.g. from OutgoingHeaders.
+// An error is returned if no signatureSecretKey is given or if an option is invalid.
+func SignRequest(req *http.Request, signatureSecretKey []byte, options ...Option) error {
+	if len(signatureSecretKey) == 0 {
+		return errors.New("signing tenant headers because secret signature key has not been configured")
+	}
+	v, err := newVerifier("", nil, nil, options)
+	if err != nil {
+		return err
+	}
+	if v.keyTransform != nil {
+		signatureSecretKey = v.keyTransform(signatureSecretKey)
+	}
+	version := v.signatureVersions[len(v.signatureVersions)-1]
+	mac := hmac.New(sha256.New, signatureSecretKey)
+	mac.Write([]byte(v.signedMessage(version, req)))
+	req.Header.Set(signatureHeaderPrefix+strconv.Itoa(version), v.encodeHeaderSignature(mac.Sum(nil)))
+	if v.initiatorSignature {
+		if message := v.initiatorSignedMessage(req); message != "" {
+			mac := hmac.New(sha256.New, signatureSecretKey)
+			mac.Write([]byte(message))
+			req.Header.Set(initiatorSignatureHeader, v.encodeHeaderSignature(mac.Sum(nil)))
+		}
+	}
+	return nil
+}
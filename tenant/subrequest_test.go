@@ -0,0 +1,335 @@
+package tenant_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestCloneWithHeaders_ClonedRequestPassesAddToCtx(t *testing.T) {
+	ctx := tenant.SetSystemBaseUri(context.Background(), "https://sample.example.com")
+	ctx = tenant.SetId(ctx, "a12be5")
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone, err := tenant.CloneWithHeaders(ctx, req, signatureKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+	tenant.AddToCtx("", signatureKey, logSpy.logError)(&handlerSpy).ServeHTTP(responseSpy, clone)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertBaseUriIs("https://sample.example.com"); err != nil {
+		t.Error(err)
+	}
+	if req.Header.Get(tenantIdHeader) != "" {
+		t.Error("original request should not have been modified")
+	}
+}
+
+func TestCloneWithHeadersOfIncomingRequest_SignedCloneIsAccepted(t *testing.T) {
+	ctx := tenant.SetSystemBaseUri(context.Background(), "https://sample.example.com")
+	ctx = tenant.SetId(ctx, "a12be5")
+	req := httptest.NewRequest("GET", "/myresource/sub", nil)
+	req.Header.Set("x-dv-sig-2", base64Signature("https://other.example.comother", signatureKey))
+	req.Header.Set("Forwarded", "host=incoming.example.com")
+	req.Header.Set("X-Forwarded-Host", "incoming.example.com")
+
+	clone, err := tenant.CloneWithHeaders(ctx, req, signatureKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tenant.SignRequest(clone, signatureKey); err != nil {
+		t.Fatal(err)
+	}
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignatureVersions(1, 2))(&handlerSpy).ServeHTTP(responseSpy, clone)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertInitiatorSystemBaseUriIs("https://sample.example.com"); err != nil {
+		t.Error(err)
+	}
+	if clone.RequestURI != "" {
+		t.Errorf("RequestURI should have been cleared but is %v", clone.RequestURI)
+	}
+}
+
+func TestCloneWithHeadersAndNoTenantOnContext_ReturnsError(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tenant.CloneWithHeaders(context.Background(), req, signatureKey); err == nil {
+		t.Error("expected error for context without tenant")
+	}
+}
+
+func TestSignMethodAndPath_RequestSignedWithSignRequestPassesAddToCtx(t *testing.T) {
+	for _, versions := range [][]int{{1}, {1, 2}} {
+		req := methodAndPathSignedRequest(t, "DELETE", "/myresource/sub", tenant.WithSignMethodAndPath(), tenant.WithSignatureVersions(versions...))
+		handlerSpy := handlerSpy{}
+		responseSpy := responseSpy{httptest.NewRecorder()}
+
+		tenant.AddToCtx("", signatureKey, nil, tenant.WithSignMethodAndPath(), tenant.WithSignatureVersions(versions...))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+		if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+			t.Errorf("signature versions %v: %v", versions, err)
+		}
+	}
+}
+
+func TestSignMethodAndPathAndChangedMethod_Returns403(t *testing.T) {
+	req := methodAndPathSignedRequest(t, "GET", "/myresource/sub", tenant.WithSignMethodAndPath())
+	req.Method = "DELETE"
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignMethodAndPath())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+}
+
+func TestSignMethodAndPathAndChangedPath_Returns403(t *testing.T) {
+	req := methodAndPathSignedRequest(t, "GET", "/myresource/sub", tenant.WithSignMethodAndPath())
+	req.URL.Path = "/myresource/other"
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignMethodAndPath())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHeaderSignatureWithoutMethodAndPathAndSignMethodAndPath_Returns403(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignMethodAndPath())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func methodAndPathSignedRequest(t *testing.T, method, path string, options ...tenant.Option) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(method, path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(systemBaseUriHeader, "https://sample.example.com")
+	req.Header.Set(tenantIdHeader, "a12be5")
+	if err := tenant.SignRequest(req, signatureKey, options...); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestAdditionalSignedData_RequestSignedWithSignRequestPassesAddToCtx(t *testing.T) {
+	req := methodAndPathSignedRequest(t, "PUT", "/documents/4711", tenant.WithAdditionalSignedData(lastPathSegment))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithAdditionalSignedData(lastPathSegment))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAdditionalSignedDataAndTamperedPathSegment_Returns403(t *testing.T) {
+	req := methodAndPathSignedRequest(t, "PUT", "/documents/4711", tenant.WithAdditionalSignedData(lastPathSegment))
+	req.URL.Path = "/documents/4712"
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithAdditionalSignedData(lastPathSegment))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+}
+
+func lastPathSegment(r *http.Request) string {
+	return path.Base(r.URL.Path)
+}
+
+func TestReorderedQueryParams_SignQueryParams_Returns200(t *testing.T) {
+	req := methodAndPathSignedRequest(t, "GET", "/callback?b=2&a=1&a=&c=3&c=1", tenant.WithSignQueryParams())
+	req.URL.RawQuery = "c=1&a=&a=1&b=2&c=3"
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignQueryParams())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestChangedQueryParams_SignQueryParams_Returns403(t *testing.T) {
+	testCases := map[string]string{
+		"changed value":       "a=1&b=3",
+		"removed empty value": "a=1&b=2",
+		"added param":         "a=1&a=&b=2&c=3",
+		"removed param":       "a=&b=2",
+	}
+	for name, query := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := methodAndPathSignedRequest(t, "GET", "/callback?a=1&a=&b=2", tenant.WithSignQueryParams())
+			req.URL.RawQuery = query
+			handlerSpy := handlerSpy{}
+			responseSpy := responseSpy{httptest.NewRecorder()}
+
+			tenant.AddToCtx("", signatureKey, nil, tenant.WithSignQueryParams())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+			if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+				t.Error(err)
+			}
+			if handlerSpy.hasBeenCalled {
+				t.Error("inner handler should not have been called")
+			}
+		})
+	}
+}
+
+func TestQueryParams_SignQueryParams_SignsCanonicalQuery(t *testing.T) {
+	req := methodAndPathSignedRequest(t, "GET", "/callback?b=%C3%A4&a=2&a=1&a=", tenant.WithSignQueryParams())
+	req.Header.Set(signatureHeader, base64Signature("https://sample.example.coma12be5a=&a=1&a=2&b=%C3%A4", signatureKey))
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignQueryParams())(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSameHost_SignHost_Returns200(t *testing.T) {
+	req := methodAndPathSignedRequest(t, "GET", "https://app.example.com/callback", tenant.WithSignHost())
+	req.Host = "APP.example.com"
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignHost())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestChangedHost_SignHost_Returns403(t *testing.T) {
+	req := methodAndPathSignedRequest(t, "GET", "https://app.example.com/callback", tenant.WithSignHost())
+	req.Host = "other-app.example.com"
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignHost())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+}
+
+func TestHost_SignHost_SignsHost(t *testing.T) {
+	req := methodAndPathSignedRequest(t, "GET", "https://app.example.com/callback", tenant.WithSignHost())
+	req.Header.Set(signatureHeader, base64Signature("https://sample.example.coma12be5app.example.com", signatureKey))
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithSignHost())(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCanonicalJSONSigning_SignsSortedCompactJSON(t *testing.T) {
+	req := methodAndPathSignedRequest(t, "GET", "/callback")
+	req.Header.Set("x-dv-note", "a<b & \"c\"")
+	req.Header.Set(signatureHeader, base64Signature(`{"x-dv-baseuri":"https://sample.example.com","x-dv-missing":"","x-dv-note":"a<b & \"c\"","x-dv-tenant-id":"a12be5"}`, signatureKey))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	option := tenant.WithCanonicalJSONSigning(tenantIdHeader, "X-Dv-Note", systemBaseUriHeader, "x-dv-missing")
+
+	tenant.AddToCtx("", signatureKey, nil, option)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSignRequest_CanonicalJSONSigning_SignatureIsAccepted(t *testing.T) {
+	option := tenant.WithCanonicalJSONSigning(systemBaseUriHeader, tenantIdHeader)
+	req := methodAndPathSignedRequest(t, "GET", "/callback", option)
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, option)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if want := base64Signature(`{"x-dv-baseuri":"https://sample.example.com","x-dv-tenant-id":"a12be5"}`, signatureKey); req.Header.Get(signatureHeader) != want {
+		t.Errorf("got wrong signature: got %v want %v", req.Header.Get(signatureHeader), want)
+	}
+}
+
+func TestChangedHeader_CanonicalJSONSigning_Returns403(t *testing.T) {
+	option := tenant.WithCanonicalJSONSigning(systemBaseUriHeader, tenantIdHeader)
+	req := methodAndPathSignedRequest(t, "GET", "/callback", option)
+	req.Header.Set(tenantIdHeader, "other")
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, option)(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDuplicateHeaders_CanonicalJSONSigning_ReturnsError(t *testing.T) {
+	if _, err := tenant.NewVerifier(tenant.WithCanonicalJSONSigning(tenantIdHeader, "X-DV-Tenant-Id")); err == nil {
+		t.Error("expected an error for duplicate headers")
+	}
+}
@@ -29,6 +29,13 @@
 //			tenant,_ := tenant.IdFromCtx(r.Context())
 //		})
 //	}
+//
+// The middleware can be used with any router which accepts a func(http.Handler) http.Handler as middleware.
+// It doesn't touch the path of the request, so path variables remain available. Example for gorilla/mux:
+//	router := mux.NewRouter()
+//	api := router.PathPrefix("/api").Subrouter()
+//	api.Use(tenant.AddToCtx(os.Getenv("systemBaseUri"), signatureSecretKey, logError))
+//	api.HandleFunc("/resource/{id}", resourceHandler)
 package tenant
 
 import (
@@ -36,10 +43,16 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
 type contextKey string
@@ -48,8 +61,17 @@ const (
 	systemBaseUriCtxKey          = contextKey("systemBaseUri")
 	tenantIdCtxKey               = contextKey("tenantId")
 	initiatorSystemBaseUriCtxKey = contextKey("sourceSystemBaseUri")
+	defaultSystemBaseUriCtxKey   = contextKey("defaultSystemBaseUri")
+	verifiedAtCtxKey             = contextKey("verifiedAt")
+	rawForwardedCtxKey           = contextKey("rawForwarded")
+	rawXForwardedHostCtxKey      = contextKey("rawXForwardedHost")
+	signatureVerifiedCtxKey      = contextKey("signatureVerified")
+	systemBaseUriSourceCtxKey    = contextKey("systemBaseUriSource")
+	impersonatedCtxKey           = contextKey("impersonated")
 	systemBaseUriHeader          = "x-dv-baseuri"
 	tenantIdHeader               = "x-dv-tenant-id"
+	signatureHeaderPrefix        = "x-dv-sig-"
+	signatureHeader              = signatureHeaderPrefix + "1"
 	forwardedHeader              = "forwarded"
 	xForwardedHostHeader         = "x-forwarded-host"
 	commaDelimiter               = ","
@@ -61,97 +83,790 @@ const (
 // Adds systemBaseUri and tenantId to request context.
 // If the headers are not present the given defaultSystemBaseUri and tenant "0" are used.
 // The signatureSecretKey is specific for each App and is provided by the registration process for d.velop cloud.
-func AddToCtx(defaultSystemBaseUri string, signatureSecretKey []byte, logger func(ctx context.Context, message string)) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-			ctx := req.Context()
+// The signature is verified against the raw header values as they have been sent. Afterwards
+// trailing slashes are removed from the systemBaseUri and initiatorSystemBaseUri before they are stored in the context.
+// The behaviour of the middleware can be adjusted by one or more options.
+func AddToCtx(defaultSystemBaseUri string, signatureSecretKey []byte, logger func(ctx context.Context, message string), options ...Option) func(http.Handler) http.Handler {
+	var signatureSecretKeys [][]byte
+	if signatureSecretKey != nil {
+		signatureSecretKeys = [][]byte{signatureSecretKey}
+	}
+	return addToCtx(defaultSystemBaseUri, signatureSecretKeys, logger, options)
+}
 
-			systemBaseUri := req.Header.Get(systemBaseUriHeader)
-			tenantId := req.Header.Get(tenantIdHeader)
+// AddToCtxWithKeyEnv works like AddToCtx but reads the signature secret keys from a single value
+// which is typically taken from an environment variable.
+// The value must contain one base64 encoded key per line. Blank lines are ignored.
+// A signature is accepted if it is valid for any of the keys. This allows to supply a primary
+// and a rotation key at the same time.
+// An error is returned if one of the lines is not valid base64 data or if the value doesn't contain any key.
+func AddToCtxWithKeyEnv(defaultSystemBaseUri string, envValue string, logger func(ctx context.Context, message string), options ...Option) (func(http.Handler) http.Handler, error) {
+	signatureSecretKeys, err := parseKeys(envValue)
+	if err != nil {
+		return nil, err
+	}
+	if len(signatureSecretKeys) == 0 {
+		return nil, errors.New("signature secret keys value doesn't contain any key")
+	}
+	return addToCtx(defaultSystemBaseUri, signatureSecretKeys, logger, options), nil
+}
 
-			if systemBaseUri != "" || tenantId != "" {
-				if signatureSecretKey == nil {
-					logger(req.Context(), fmt.Sprintf("validating signature for headers '%v' and '%v' because secret signature key has not been configured", systemBaseUriHeader, tenantIdHeader))
-					http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-					return
-				}
-				base64Signature := req.Header.Get("x-dv-sig-1")
-				signature, err := base64.StdEncoding.DecodeString(base64Signature)
-				if err != nil {
-					logger(req.Context(), fmt.Sprintf("decoding signature '%v' as base 64 data because: %v", base64Signature, err))
-					http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
-					return
-				}
-				if !signatureIsValid([]byte(systemBaseUri+tenantId), []byte(signature), signatureSecretKey) {
-					logger(req.Context(), fmt.Sprintf("signature '%v' is not valid for SystemBaseUri '%v' and TenantId '%v'", signature, systemBaseUri, tenantId))
-					http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
-					return
-				}
+func parseKeys(envValue string) ([][]byte, error) {
+	var keys [][]byte
+	for i, line := range strings.Split(envValue, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, fmt.Errorf("decoding signature secret key in line %v as base 64 data because: %v", i+1, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func addToCtx(defaultSystemBaseUri string, signatureSecretKeys [][]byte, logger func(ctx context.Context, message string), options []Option) func(http.Handler) http.Handler {
+	v, optionErr := newVerifier(defaultSystemBaseUri, signatureSecretKeys, logger, options)
+	if logger == nil {
+		logger = func(ctx context.Context, message string) {}
+	}
+	return func(next http.Handler) http.Handler {
+		if optionErr != nil {
+			return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				logger(req.Context(), fmt.Sprintf("configuring tenant middleware because: %v", optionErr))
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			})
+		}
+		return v.handler(next)
+	}
+}
+
+func newVerifier(defaultSystemBaseUri string, signatureSecretKeys [][]byte, logger func(ctx context.Context, message string), options []Option) (*Verifier, error) {
+	if logger == nil {
+		logger = func(ctx context.Context, message string) {}
+	}
+	v := &Verifier{
+		defaultSystemBaseUri: defaultSystemBaseUri,
+		logger:               logger,
+		maxBaseUriLength:     defaultMaxBaseUriLength,
+		initiatorHeaders:     []string{forwardedHeader, xForwardedHostHeader},
+		signatureVersions:    []int{1},
+		now:                  time.Now,
+		missingKeyStatus:     http.StatusInternalServerError,
+		initiatorFallback:    true,
+		replayWindow:         defaultReplayWindow,
+		maxBodySize:          defaultMaxBodySize,
+	}
+	if len(signatureSecretKeys) > 0 {
+		v.keyProvider = staticKeyProvider(signatureSecretKeys)
+	}
+	for _, option := range options {
+		if err := option(v); err != nil {
+			return nil, err
+		}
+	}
+	if v.nonceStore != nil && v.timestampHeader == "" && v.signedExpiryHeader == "" {
+		return nil, errors.New("nonce store requires a signed timestamp or expiry (WithTimestampInMessage or WithSignedExpiryHeader)")
+	}
+	if v.testModeRequireHeaders {
+		v.logger(context.Background(), "WARNING: tenant middleware is running in test mode. Signatures are not verified. Never use WithTestModeRequireHeaders in production.")
+	}
+	if keys, ok := v.keyProvider.(staticKeyProvider); ok {
+		for i, key := range keys {
+			if isWeakKey(key) {
+				v.logger(context.Background(), fmt.Sprintf("WARNING: signature secret key %v is weak because it consists of a single repeated byte or is the example value from the documentation. Please configure the key provided by the registration process for d.velop cloud.", i+1))
 			}
+		}
+		if v.keyTransform != nil {
+			v.keyProvider = staticKeyProvider(transformKeys(v.keyTransform, keys))
+		}
+	}
+	return v, nil
+}
+
+// Sentinel errors which describe why a signature has been rejected. The errors returned by ValidateRequest,
+// CtxFromMap and VerifyDetached wrap them, so use errors.Is to check the reason.
+var (
+	// ErrMissingSignature is returned if a signature is required but missing.
+	ErrMissingSignature = errors.New("signature is missing")
+	// ErrMalformedSignature is returned if a signature is not valid base 64 data.
+	ErrMalformedSignature = errors.New("signature is not valid base 64 data")
+	// ErrInvalidSignature is returned if a signature doesn't match the signed data.
+	ErrInvalidSignature = errors.New("signature is not valid")
+	// ErrUnacceptedSignatureVersion is returned if the signature version is not accepted.
+	ErrUnacceptedSignatureVersion = errors.New("signature version is not accepted")
+	// ErrMissingSignatureKey is returned if no signature secret key has been configured.
+	ErrMissingSignatureKey = errors.New("secret signature key has not been configured")
+)
 
-			if tenantId == "" {
-				// tenant 0 is reserved for environments which don't support multitenancy and
-				// therefore can not transmit tenant headers. So there is only one tenant "0".
-				// As soon as this environment supports additonal tenants these additional tenants will
-				// have an id != "0"
-				tenantId = "0"
+// verificationError describes why a request has been rejected and which status code is returned to the caller.
+type verificationError struct {
+	status  int
+	message string
+	// err is the sentinel error, e.g. ErrInvalidSignature, which describes the category of the error. It may be nil.
+	err error
+}
+
+func (e *verificationError) Error() string {
+	return e.message
+}
+
+// Unwrap returns the sentinel error so that errors.Is can be used to check the category of the error.
+func (e *verificationError) Unwrap() error {
+	return e.err
+}
+
+func (v *Verifier) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		logCtx := req.Context()
+		if v.accessLog != nil {
+			recorder := newStatusRecorder(rw)
+			defer func() { v.accessLog(logCtx, recorder.statusCode) }()
+			rw = recorder
+		}
+		event := AuditEvent{
+			TenantId:      headerValue(req.Header, tenantIdHeader),
+			SystemBaseUri: headerValue(req.Header, systemBaseUriHeader),
+		}
+		start := time.Now()
+		info, warnings, claims, vErr := v.validate(req)
+		if v.serverTiming {
+			addServerTiming(rw.Header(), time.Since(start))
+		}
+		if vErr != nil {
+			v.logger(req.Context(), vErr.message)
+			v.expvarCounters.countRejected(vErr)
+			v.sendOutcome(event.TenantId, false, vErr.message)
+			event.Reason = vErr.message
+			v.audit(req.Context(), event)
+			if v.missingKeyHandler != nil && errors.Is(vErr, ErrMissingSignatureKey) {
+				v.serveMissingKey(rw, req)
+				return
 			}
-			if tenantId != "" {
-				ctx = context.WithValue(ctx, tenantIdCtxKey, tenantId)
+			http.Error(rw, http.StatusText(vErr.status), vErr.status)
+			return
+		}
+		ctx := newCtx(req.Context(), info)
+		ctx = context.WithValue(ctx, systemBaseUriSourceCtxKey, systemBaseUriSource(info, warnings))
+		if v.defaultSystemBaseUri != "" {
+			ctx = context.WithValue(ctx, defaultSystemBaseUriCtxKey, normalizeBaseUri(v.defaultSystemBaseUri))
+		}
+		ctx = context.WithValue(ctx, verifiedAtCtxKey, v.now())
+		ctx = context.WithValue(ctx, rawForwardedCtxKey, headerValue(req.Header, forwardedHeader))
+		ctx = context.WithValue(ctx, rawXForwardedHostCtxKey, headerValue(req.Header, xForwardedHostHeader))
+		if v.tenantLogger != nil {
+			ctx = context.WithValue(ctx, v.tenantLoggerKey, v.tenantLogger(info))
+		}
+		if v.signedExpiryHeader != "" && !claims.expiry.IsZero() {
+			// the expiry is taken from the expiry header or the claim exp of a JWT. Cookies have no deadline.
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, claims.expiry)
+			defer cancel()
+		}
+		event.Accepted = true
+		if err := v.audit(ctx, event); err != nil && v.failClosedOnAuditError {
+			v.expvarCounters.countRejected(err)
+			v.sendOutcome(info.TenantId, false, fmt.Sprintf("recording audit event because: %v", err))
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		v.expvarCounters.countAccepted()
+		v.sendOutcome(info.TenantId, true, "")
+		if v.successLogger != nil {
+			v.successLogger(ctx, fmt.Sprintf("accepted request for TenantId '%v' and SystemBaseUri '%v'", v.loggedTenantId(info.TenantId), info.SystemBaseUri))
+		}
+		logCtx = ctx
+		if v.responseBaggage {
+			bw := newBaggageWriter(rw, info.TenantId)
+			// the handler may return without writing the response
+			defer bw.addBaggage()
+			rw = bw
+		}
+		req = req.WithContext(ctx)
+		if v.normalizeForwarded {
+			v.setNormalizedForwardedHeader(req)
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// tenantSource is the source from which the tenant information of a request has been taken.
+type tenantSource int
+
+const (
+	sourceHeaders tenantSource = iota
+	sourceJWT
+	sourceCookie
+)
+
+// sourceClaims contains the values of the source of the tenant information which are covered by its signature.
+type sourceClaims struct {
+	source tenantSource
+	// systemBaseUri is the systemBaseUri claimed by the source without any default or normalization.
+	systemBaseUri string
+	// tenantId is the tenant id claimed by the source without any default.
+	tenantId string
+	// expiry is the signed expiry of the request. It is zero if the source doesn't contain one.
+	expiry time.Time
+	// issuedAt is the signed time at which the request has been signed. It is zero if the source doesn't contain one.
+	issuedAt time.Time
+	// nonce is the signed nonce of the request. It is empty if the source doesn't contain one.
+	nonce string
+}
+
+// validate checks the signature of the tenant headers and returns the tenant information of the request
+// together with the signed values of its source.
+func (v *Verifier) validate(req *http.Request) (TenantInfo, []Warning, sourceClaims, *verificationError) {
+	if vErr := v.checkHeaderLengths(req); vErr != nil {
+		return TenantInfo{}, nil, sourceClaims{}, vErr
+	}
+	info, claims, warnings, vErr := v.validateSource(req)
+	if vErr != nil {
+		return TenantInfo{}, nil, sourceClaims{}, vErr
+	}
+	if vErr := v.checkUUIDTenantId(claims.tenantId); vErr != nil {
+		return TenantInfo{}, nil, sourceClaims{}, vErr
+	}
+	if vErr := v.validateRequest(req, info, &claims); vErr != nil {
+		return TenantInfo{}, nil, sourceClaims{}, vErr
+	}
+	if info.SignatureVerified {
+		if vErr := v.checkTenantActive(req.Context(), info.TenantId); vErr != nil {
+			return TenantInfo{}, nil, sourceClaims{}, vErr
+		}
+	}
+	// the nonce is recorded last so that it isn't consumed by a request which is rejected anyway.
+	// Cookies are sent with every request of a session, so they don't contain a nonce.
+	if v.nonceStore != nil && claims.source != sourceCookie {
+		if vErr := v.checkNonce(req.Context(), claims); vErr != nil {
+			return TenantInfo{}, nil, sourceClaims{}, vErr
+		}
+	}
+	return info, warnings, claims, nil
+}
+
+// validateSource returns the tenant information of the request taken from a JWT, a cookie or the tenant headers
+// and the values which are covered by the signature of the source.
+func (v *Verifier) validateSource(req *http.Request) (TenantInfo, sourceClaims, []Warning, *verificationError) {
+	if v.jwtKeyFunc != nil {
+		if token, ok := bearerJWT(req); ok {
+			info, claims, vErr := v.validateJWT(token)
+			if vErr == nil {
+				vErr = v.checkAllowedHost(info.SystemBaseUri)
 			}
+			return info, claims, nil, vErr
+		}
+	}
+	if cookie, ok := v.tenantCookie(req); ok {
+		info, claims, vErr := v.validateCookie(req, cookie)
+		if vErr == nil {
+			vErr = v.checkAllowedHost(info.SystemBaseUri)
+		}
+		return info, claims, nil, vErr
+	}
+	info, warnings, vErr := v.validateHeaders(req)
+	claims := sourceClaims{
+		source:        sourceHeaders,
+		systemBaseUri: v.signedHeaderValue(req.Header, systemBaseUriHeader),
+		tenantId:      v.signedHeaderValue(req.Header, tenantIdHeader),
+	}
+	return info, claims, warnings, vErr
+}
+
+// validateRequest performs the checks which apply to the tenant information from every source. The host of
+// the SystemBaseUri has already been checked against WithAllowedHosts by validateSource.
+func (v *Verifier) validateRequest(req *http.Request, info TenantInfo, claims *sourceClaims) *verificationError {
+	if vErr := v.checkInitiator(info.InitiatorSystemBaseUri); vErr != nil {
+		return vErr
+	}
+	if vErr := v.checkReplayProtection(req, claims); vErr != nil {
+		return vErr
+	}
+
+	// the body is read only after all checks which solely depend on the headers have been passed
+	if v.bodySignature {
+		if vErr := v.verifyBodySignature(req, *claims); vErr != nil {
+			return vErr
+		}
+	} else if v.formBodySignature {
+		if vErr := v.verifyFormBodySignature(req, *claims); vErr != nil {
+			return vErr
+		}
+	} else if v.streamingBodySignature {
+		if vErr := v.verifyBodySignatureWhileReading(req, *claims); vErr != nil {
+			return vErr
+		}
+	}
+	return nil
+}
 
-			if systemBaseUri == "" {
-				systemBaseUri = defaultSystemBaseUri
+// validateHeaders checks the signature of the tenant headers and returns the tenant information of the headers.
+func (v *Verifier) validateHeaders(req *http.Request) (TenantInfo, []Warning, *verificationError) {
+	var warnings []Warning
+	systemBaseUri := v.signedHeaderValue(req.Header, systemBaseUriHeader)
+	tenantId := v.signedHeaderValue(req.Header, tenantIdHeader)
+
+	if tenantId == "" && headerPresent(req.Header, tenantIdHeader) && !v.emptyTenantIdAsDefault {
+		return TenantInfo{}, nil, &verificationError{http.StatusBadRequest, fmt.Sprintf("empty tenant id: header '%v' is present but empty", tenantIdHeader), nil}
+	}
+
+	if v.requireBothIdentityHeaders && (systemBaseUri == "") != (tenantId == "") {
+		return TenantInfo{}, nil, &verificationError{http.StatusBadRequest, fmt.Sprintf("incomplete identity: headers '%v' and '%v' must be sent together but got SystemBaseUri '%v' and TenantId '%v'", systemBaseUriHeader, tenantIdHeader, systemBaseUri, v.loggedTenantId(tenantId)), nil}
+	}
+
+	if v.testModeRequireHeaders && (systemBaseUri == "" || tenantId == "") {
+		return TenantInfo{}, nil, &verificationError{http.StatusBadRequest, fmt.Sprintf("missing identity: headers '%v' and '%v' are required in test mode but got SystemBaseUri '%v' and TenantId '%v'", systemBaseUriHeader, tenantIdHeader, systemBaseUri, v.loggedTenantId(tenantId)), nil}
+	}
+
+	// the allowed hosts are checked before the signature so that requests for other hosts neither
+	// trigger a key lookup nor have their body read or their nonce recorded
+	if v.allowedHosts != nil {
+		allowedHostUri := systemBaseUri
+		if allowedHostUri == "" {
+			allowedHostUri = v.defaultSystemBaseUri
+		}
+		if vErr := v.checkAllowedHost(v.addMissingScheme(normalizeBaseUri(allowedHostUri))); vErr != nil {
+			return TenantInfo{}, nil, vErr
+		}
+	}
+
+	version, base64Signature, vErr := v.signatureFromRequest(req)
+	if vErr != nil {
+		return TenantInfo{}, nil, vErr
+	}
+	mustVerify := (systemBaseUri != "" || tenantId != "" || base64Signature != "") && !v.testModeRequireHeaders
+	signatureVerified := mustVerify
+	if mustVerify {
+		scheme, vErr := v.requestSignatureScheme(req, tenantId)
+		if vErr != nil {
+			return TenantInfo{}, nil, vErr
+		}
+		if vErr := v.verifySignature(req, scheme, version, base64Signature); vErr != nil {
+			if v.enforces(req.Context(), tenantId, vErr) {
+				return TenantInfo{}, nil, vErr
 			}
-			if systemBaseUri != "" {
-				ctx = context.WithValue(ctx, systemBaseUriCtxKey, systemBaseUri)
+			signatureVerified = false
+		} else if version < v.signatureVersions[len(v.signatureVersions)-1] {
+			warnings = append(warnings, Warning{WarningDeprecatedSignatureVersion, fmt.Sprintf("request is signed with signature version %v but version %v is supported", version, v.signatureVersions[len(v.signatureVersions)-1])})
+		}
+		if v.initiatorSignature && signatureVerified {
+			if vErr := v.verifyInitiatorSignature(req, scheme); vErr != nil {
+				if v.enforces(req.Context(), tenantId, vErr) {
+					return TenantInfo{}, nil, vErr
+				}
+				signatureVerified = false
 			}
+		}
+	}
 
-			initiatorSystemBaseUri := getInitiatorSystemBaseUri(req)
-			if initiatorSystemBaseUri == "" {
-				initiatorSystemBaseUri = defaultSystemBaseUri
-			}
-			if initiatorSystemBaseUri != "" {
-				ctx = context.WithValue(ctx, initiatorSystemBaseUriCtxKey, initiatorSystemBaseUri)
+	info, defaultWarnings := v.headerInfo(req, systemBaseUri, tenantId, signatureVerified)
+	return info, append(warnings, defaultWarnings...), nil
+}
+
+// headerInfo returns the tenant information of the tenant headers with the given values. The defaults are
+// applied to missing values.
+func (v *Verifier) headerInfo(req *http.Request, systemBaseUri, tenantId string, signatureVerified bool) (TenantInfo, []Warning) {
+	var warnings []Warning
+	if tenantId == "" {
+		// tenant 0 is reserved for environments which don't support multitenancy and
+		// therefore can not transmit tenant headers. So there is only one tenant "0".
+		// As soon as this environment supports additonal tenants these additional tenants will
+		// have an id != "0"
+		tenantId = "0"
+	}
+
+	if systemBaseUri == "" && v.defaultSystemBaseUri != "" {
+		systemBaseUri = v.defaultSystemBaseUri
+		warnings = append(warnings, Warning{WarningDefaultBaseUriUsed, fmt.Sprintf("header '%v' is missing so the default SystemBaseUri '%v' is used", systemBaseUriHeader, v.defaultSystemBaseUri)})
+	}
+
+	initiatorSystemBaseUri := v.getInitiatorSystemBaseUri(req)
+	if initiatorSystemBaseUri == "" && v.initiatorFallback {
+		initiatorSystemBaseUri = v.defaultSystemBaseUri
+	}
+	return TenantInfo{
+		TenantId:               tenantId,
+		SystemBaseUri:          v.enforceScheme(req.Context(), v.addMissingScheme(normalizeBaseUri(systemBaseUri))),
+		InitiatorSystemBaseUri: v.addMissingScheme(normalizeBaseUri(initiatorSystemBaseUri)),
+		SignatureVerified:      signatureVerified,
+	}, warnings
+}
+
+// newCtx returns a new context which contains the given tenant information.
+func newCtx(ctx context.Context, info TenantInfo) context.Context {
+	if info.TenantId != "" {
+		ctx = context.WithValue(ctx, tenantIdCtxKey, info.TenantId)
+	}
+	if info.SystemBaseUri != "" {
+		ctx = context.WithValue(ctx, systemBaseUriCtxKey, info.SystemBaseUri)
+	}
+	if info.InitiatorSystemBaseUri != "" {
+		ctx = context.WithValue(ctx, initiatorSystemBaseUriCtxKey, info.InitiatorSystemBaseUri)
+	}
+	if info.SignatureVerified {
+		ctx = context.WithValue(ctx, signatureVerifiedCtxKey, true)
+	}
+	return ctx
+}
+
+// verifySignature verifies the signature of the tenant headers of req.
+func (v *Verifier) verifySignature(req *http.Request, scheme SignatureScheme, version int, base64Signature string) *verificationError {
+	message := v.signedMessage(version, req)
+	signature, err := v.decodeHeaderSignature(base64Signature)
+	if err != nil {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("decoding signature '%v' because: %v", base64Signature, err), ErrMalformedSignature}
+	}
+	fingerprint := ""
+	if v.verificationCache != nil {
+		fingerprint = keySetFingerprint(scheme)
+	}
+	if !v.verificationCache.contains(fingerprint, version, message, signature) {
+		if !v.verifyAndReportKey(req.Context(), scheme, []byte(message), signature) {
+			tenantId := v.loggedTenantId(headerValue(req.Header, tenantIdHeader))
+			return &verificationError{http.StatusForbidden, fmt.Sprintf("signature '%v' is not valid for SystemBaseUri '%v' and TenantId '%v'", signature, headerValue(req.Header, systemBaseUriHeader), tenantId), ErrInvalidSignature}
+		}
+		v.verificationCache.add(fingerprint, version, message, signature)
+	}
+	return nil
+}
+
+// signatureFromRequest returns the highest supported signature version which is present in the request.
+// If the request isn't signed at all the lowest supported version and an empty signature are returned.
+//
+// The signature may carry its version as prefix like "v2=<base64>". In this case the version of the prefix
+// is used regardless of the header. A signature without prefix has the version of the header.
+func (v *Verifier) signatureFromRequest(req *http.Request) (int, string, *verificationError) {
+	headerVersions := v.signatureVersions
+	if !v.acceptsSignatureVersion(1) {
+		// gateways may send prefixed signatures of any version in the header x-dv-sig-1
+		headerVersions = append([]int{1}, headerVersions...)
+	}
+	for i := len(headerVersions) - 1; i >= 0; i-- {
+		headerVersion := headerVersions[i]
+		value := headerValue(req.Header, signatureHeaderPrefix+strconv.Itoa(headerVersion))
+		if value == "" {
+			continue
+		}
+		if version, base64Signature, ok := splitSignatureVersionPrefix(value); ok {
+			if !v.acceptsSignatureVersion(version) {
+				return 0, "", &verificationError{http.StatusForbidden, fmt.Sprintf("signature version %v is not accepted", version), ErrUnacceptedSignatureVersion}
 			}
-			next.ServeHTTP(rw, req.WithContext(ctx))
-		})
+			return v.checkMinSignatureVersion(version, base64Signature)
+		}
+		if v.acceptsSignatureVersion(headerVersion) {
+			return v.checkMinSignatureVersion(headerVersion, value)
+		}
+	}
+	return v.signatureVersions[0], "", nil
+}
+
+// checkMinSignatureVersion rejects signatures whose version is below the version set by WithMinSignatureVersion.
+func (v *Verifier) checkMinSignatureVersion(version int, base64Signature string) (int, string, *verificationError) {
+	if version < v.minSignatureVersion {
+		return 0, "", &verificationError{http.StatusForbidden, fmt.Sprintf("signature version %v is below the minimum signature version %v", version, v.minSignatureVersion), ErrUnacceptedSignatureVersion}
 	}
+	return version, base64Signature, nil
 }
 
-func signatureIsValid(message, signature, key []byte) bool {
+func (v *Verifier) acceptsSignatureVersion(version int) bool {
+	for _, accepted := range v.signatureVersions {
+		if accepted == version {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSignatureVersionPrefix splits a signature like "v2=<base64>" into its version and the base64 data.
+func splitSignatureVersionPrefix(value string) (int, string, bool) {
+	if len(value) < 3 || value[0] != 'v' {
+		return 0, "", false
+	}
+	i := strings.IndexByte(value, '=')
+	if i < 2 || i == len(value)-1 {
+		return 0, "", false
+	}
+	version, err := strconv.Atoi(value[1:i])
+	if err != nil {
+		return 0, "", false
+	}
+	return version, value[i+1:], true
+}
+
+// signedMessage returns the message which is signed for the given signature version.
+//
+// Version 1 signs the concatenation of the headers x-dv-baseuri and x-dv-tenant-id (cf. SignedMessage).
+// Version 2 separates both values by a newline so that different combinations of the values
+// can't result in the same message.
+// If neither header is present the message is ForwardedSignedMessage for all versions.
+// If WithSignedInitiator is used the initiatorSystemBaseUri is appended for all versions,
+// also if neither header is present.
+// If WithSignMethodAndPath is used the method and the path of the request are appended.
+// If WithSignedExpiryHeader is used the value of the expiry header is appended.
+// If WithAdditionalSignedData is used the data returned by the callback is appended last.
+func (v *Verifier) signedMessage(version int, req *http.Request) string {
+	systemBaseUri := v.signedHeaderValue(req.Header, systemBaseUriHeader)
+	tenantId := v.signedHeaderValue(req.Header, tenantIdHeader)
+	var message string
+	switch {
+	case v.canonicalJSONFields != nil:
+		message = v.canonicalJSON(req)
+	case v.signedInitiator:
+		message = appendToSignedMessage(version, systemBaseUri, tenantId)
+		if initiatorSystemBaseUri := v.getForwardedInitiatorSystemBaseUri(req); initiatorSystemBaseUri != "" {
+			message = appendToSignedMessage(version, message, initiatorSystemBaseUri)
+		}
+	case systemBaseUri == "" && tenantId == "":
+		message = ForwardedSignedMessage(req)
+	default:
+		message = appendToSignedMessage(version, systemBaseUri, tenantId)
+	}
+	if v.signMethodAndPath {
+		message = appendToSignedMessage(version, message, req.Method, req.URL.Path)
+	}
+	if v.signQueryParams {
+		message = appendToSignedMessage(version, message, canonicalQuery(req.URL.Query()))
+	}
+	if v.signHost {
+		message = appendToSignedMessage(version, message, requestHost(req))
+	}
+	if v.signForwardedChain {
+		message = appendToSignedMessage(version, message, strings.Join(v.forwardedChain(req), commaDelimiter))
+	}
+	if v.signedExpiryHeader != "" {
+		message = appendToSignedMessage(version, message, headerValue(req.Header, v.signedExpiryHeader))
+	}
+	if v.timestampHeader != "" {
+		message = appendToSignedMessage(version, message, v.signedTimestamp(req))
+	}
+	if v.nonceStore != nil {
+		message = appendToSignedMessage(version, message, headerValue(req.Header, nonceHeader))
+	}
+	if v.additionalSignedData != nil {
+		if data := v.additionalSignedData(req); data != "" {
+			message = appendToSignedMessage(version, message, data)
+		}
+	}
+	return message
+}
+
+// appendToSignedMessage appends the values to the message. Version 1 concatenates the values
+// whereas version 2 separates them by a newline.
+func appendToSignedMessage(version int, message string, values ...string) string {
+	separator := ""
+	if version == 2 {
+		separator = "\n"
+	}
+	for _, value := range values {
+		message += separator + value
+	}
+	return message
+}
+
+// SignedMessage returns the message which must be signed for signature version 1.
+//
+// The message is the concatenation of the systemBaseUri, the tenantId and the initiatorSystemBaseUri
+// in this order. Each value is only included if it is present. The values must be taken as they are sent
+// in the headers without any normalization.
+// The initiatorSystemBaseUri is only part of the message if the receiving App uses WithSignedInitiator.
+// It is the uri "https://<host>" derived from the forwarded headers. Otherwise pass an empty string.
+func SignedMessage(systemBaseUri, tenantId, initiatorSystemBaseUri string) string {
+	return systemBaseUri + tenantId + initiatorSystemBaseUri
+}
+
+// checkHeaderLengths rejects oversized headers before any expensive work like signature validation is done.
+func (v *Verifier) checkHeaderLengths(req *http.Request) *verificationError {
+	if len(headerValue(req.Header, systemBaseUriHeader)) > v.maxBaseUriLength {
+		return &verificationError{http.StatusBadRequest, fmt.Sprintf("baseuri too long: header '%v' exceeds %v characters", systemBaseUriHeader, v.maxBaseUriLength), nil}
+	}
+	if len(headerValue(req.Header, tenantIdHeader)) > v.maxBaseUriLength {
+		return &verificationError{http.StatusBadRequest, fmt.Sprintf("tenant id too long: header '%v' exceeds %v characters", tenantIdHeader, v.maxBaseUriLength), nil}
+	}
+	for _, header := range []string{forwardedHeader, xForwardedHostHeader} {
+		if len(headerValue(req.Header, header)) > v.maxBaseUriLength {
+			return &verificationError{http.StatusBadRequest, fmt.Sprintf("forwarded header too long: header '%v' exceeds %v characters", header, v.maxBaseUriLength), nil}
+		}
+	}
+	return nil
+}
+
+// normalizeBaseUri removes trailing slashes so that "https://x.example.com/" and "https://x.example.com"
+// are stored identically. It must only be applied after the signature has been verified because
+// the signature covers the header value exactly as it has been sent.
+func normalizeBaseUri(baseUri string) string {
+	return strings.TrimRight(baseUri, "/")
+}
+
+// enforceScheme replaces the scheme of the baseUri by the scheme set by WithForceScheme and logs a warning if it does so.
+func (v *Verifier) enforceScheme(ctx context.Context, baseUri string) string {
+	if v.forcedScheme == "" {
+		return baseUri
+	}
+	index := strings.Index(baseUri, "://")
+	if index < 0 || strings.EqualFold(baseUri[:index], v.forcedScheme) {
+		return baseUri
+	}
+	rewritten := v.forcedScheme + baseUri[index:]
+	v.logger(ctx, fmt.Sprintf("WARNING: rewriting scheme of SystemBaseUri '%v' to '%v'", baseUri, rewritten))
+	return rewritten
+}
+
+// addMissingScheme prepends "https://" to a baseUri without scheme if WithAssumeHTTPS is used.
+func (v *Verifier) addMissingScheme(baseUri string) string {
+	if !v.assumeHTTPS || baseUri == "" || strings.Contains(baseUri, "://") {
+		return baseUri
+	}
+	return uriPrefix + baseUri
+}
+
+// decodeSignature decodes a base64 encoded signature. Whitespace and line breaks are removed before
+// decoding because legacy encoders wrap the data every 76 characters (MIME) and wrapped header values
+// may arrive with spaces instead of line breaks.
+func decodeSignature(base64Signature string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(base64Signature), ""))
+}
+
+// signatureIsValidForAnyKey computes one HMAC per key until a key matches. So the cost is O(N) in the
+// number of keys. The message is built only once by the caller and the buffer for the HMAC is reused.
+func signatureIsValidForAnyKey(message, signature []byte, keys [][]byte) bool {
+	return matchingKeyIndex(message, signature, keys) >= 0
+}
+
+// matchingKeyIndex returns the index of the first key whose HMAC of message matches signature or -1 if none matches.
+func matchingKeyIndex(message, signature []byte, keys [][]byte) int {
+	// no HMAC can match a signature of the wrong length
+	if len(signature) != sha256.Size {
+		return -1
+	}
+	var expectedMAC [sha256.Size]byte
+	for i, key := range keys {
+		if signatureIsValid(message, signature, key, expectedMAC[:0]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// signatureIsValid appends the HMAC to buf which may be used to avoid allocations.
+func signatureIsValid(message, signature, key, buf []byte) bool {
 	mac := hmac.New(sha256.New, key)
 	mac.Write(message)
-	expectedMAC := mac.Sum(nil)
+	expectedMAC := mac.Sum(buf)
 	return hmac.Equal(signature, expectedMAC)
 }
 
+// ForwardedSignedMessage returns the message which must be signed for requests which don't contain
+// the headers x-dv-baseuri and x-dv-tenant-id but only the forwarded headers.
+//
+// The message is the raw value of the Forwarded header if present and the raw value of the
+// X-Forwarded-Host header otherwise. If neither header is present the message is empty.
+// Such requests are only verified if they contain a signature. Requests which contain x-dv-baseuri or
+// x-dv-tenant-id are always verified and the signed message is the concatenation of both header values.
+func ForwardedSignedMessage(r *http.Request) string {
+	if forwarded := headerValue(r.Header, forwardedHeader); forwarded != "" {
+		return forwarded
+	}
+	return headerValue(r.Header, xForwardedHostHeader)
+}
+
+// setNormalizedForwardedHeader replaces the forwarded headers of the request with a single Forwarded header
+// which contains the host of the initiatorSystemBaseUri, e.g. Forwarded: host="x.example.com";proto=https.
+// The request is left unchanged if it doesn't contain forwarded headers.
+func (v *Verifier) setNormalizedForwardedHeader(req *http.Request) {
+	initiatorSystemBaseUri := v.getForwardedInitiatorSystemBaseUri(req)
+	if initiatorSystemBaseUri == "" {
+		return
+	}
+	host := strings.TrimPrefix(normalizeBaseUri(initiatorSystemBaseUri), uriPrefix)
+	// the header is cloned because it is shared with the request of the caller
+	req.Header = req.Header.Clone()
+	deleteForwardedHeaders(req.Header)
+	req.Header.Set(forwardedHeader, fmt.Sprintf("host=%q;proto=https", host))
+}
+
+// deleteForwardedHeaders removes the headers Forwarded and x-forwarded-host from header regardless of the case
+// of their names because headers which haven't been set via Header.Set may not be canonicalized.
+func deleteForwardedHeaders(header http.Header) {
+	for name := range header {
+		if strings.EqualFold(name, forwardedHeader) || strings.EqualFold(name, xForwardedHostHeader) {
+			delete(header, name)
+		}
+	}
+}
+
 // returns the initial host which initiates current request
 // it is essential in hybrid systems
-func getInitiatorSystemBaseUri(req *http.Request) string {
-	var initiatorSystemBaseUri string
-	forwardedHeaderValue := req.Header.Get(forwardedHeader)
-	xForwardedHostHeaderValue := req.Header.Get(xForwardedHostHeader)
-	systemBaseUri := req.Header.Get(systemBaseUriHeader)
+func (v *Verifier) getInitiatorSystemBaseUri(req *http.Request) string {
+	if initiatorSystemBaseUri := v.getForwardedInitiatorSystemBaseUri(req); initiatorSystemBaseUri != "" || !v.initiatorFallback {
+		return initiatorSystemBaseUri
+	}
+	return headerValue(req.Header, systemBaseUriHeader)
+}
 
-	initiatorSystemBaseUri = getForwardedHeaderFirstHostValueAsUri(forwardedHeaderValue)
-	if initiatorSystemBaseUri == "" {
-		host := getFirstValueOfDelimitedList(xForwardedHostHeaderValue, commaDelimiter)
-		if host != "" {
-			initiatorSystemBaseUri = uriPrefix + host
-		} else {
-			initiatorSystemBaseUri = systemBaseUri
+// returns the initiatorSystemBaseUri derived from the forwarded headers or an empty string if they are not present
+func (v *Verifier) getForwardedInitiatorSystemBaseUri(req *http.Request) string {
+	for _, header := range v.initiatorHeaders {
+		var initiatorSystemBaseUri string
+		switch header {
+		case forwardedHeader:
+			initiatorSystemBaseUri = getForwardedHeaderFirstHostValueAsUri(headerValue(req.Header, forwardedHeader))
+		case xForwardedHostHeader:
+			if host := strings.TrimSpace(getFirstValueOfDelimitedList(headerValue(req.Header, xForwardedHostHeader), commaDelimiter)); host != "" {
+				initiatorSystemBaseUri = uriPrefix + host
+			}
+		}
+		if initiatorSystemBaseUri != "" && v.validateForwardedHost && !isValidHost(strings.TrimPrefix(normalizeBaseUri(initiatorSystemBaseUri), uriPrefix)) {
+			v.logger(req.Context(), fmt.Sprintf("invalid forwarded host in header '%v': ignoring initiatorSystemBaseUri '%v'", header, initiatorSystemBaseUri))
+			initiatorSystemBaseUri = ""
+		}
+		if initiatorSystemBaseUri != "" && !isParseableHost(strings.TrimPrefix(normalizeBaseUri(initiatorSystemBaseUri), uriPrefix)) {
+			v.logger(req.Context(), fmt.Sprintf("WARNING: malformed forwarded host in header '%v': ignoring initiatorSystemBaseUri '%v'", header, initiatorSystemBaseUri))
+			initiatorSystemBaseUri = ""
+		}
+		if initiatorSystemBaseUri != "" {
+			return initiatorSystemBaseUri
 		}
 	}
-	return initiatorSystemBaseUri
+	return ""
 }
 
-func getForwardedHeaderFirstHostValueAsUri(headerValue string) string {
-	if headerValue != "" {
-		for _, value := range strings.Split(headerValue, colonDelimiter) {
-			if strings.HasPrefix(value, forwardedHostPattern) {
-				hostValue := strings.TrimPrefix(value, forwardedHostPattern)
-				host := getFirstValueOfDelimitedList(hostValue, commaDelimiter)
+// isParseableHost reports whether host can be parsed as the host of an uri, e.g. it isn't empty and doesn't contain
+// whitespace or a path. Unlike isValidHost it doesn't restrict the characters of the host name.
+func isParseableHost(host string) bool {
+	if strings.IndexFunc(host, unicode.IsSpace) >= 0 {
+		return false
+	}
+	u, err := url.Parse(uriPrefix + host)
+	return err == nil && u.Host == host && u.Hostname() != "" && u.User == nil
+}
+
+// isValidHost reports whether host is a syntactically valid host with an optional port.
+func isValidHost(host string) bool {
+	u, err := url.Parse(uriPrefix + host)
+	if err != nil || u.Host != host || u.Hostname() == "" || u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+		return false
+	}
+	if net.ParseIP(u.Hostname()) != nil {
+		return true
+	}
+	for _, r := range u.Hostname() {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func getForwardedHeaderFirstHostValueAsUri(forwardedValue string) string {
+	if forwardedValue != "" {
+		for _, value := range strings.Split(forwardedValue, colonDelimiter) {
+			// parameter names are case-insensitive and may be surrounded by whitespace (cf. RFC 7239 section 4)
+			value = strings.TrimSpace(value)
+			if len(value) >= len(forwardedHostPattern) && strings.EqualFold(value[:len(forwardedHostPattern)], forwardedHostPattern) {
+				hostValue := value[len(forwardedHostPattern):]
+				host := strings.Trim(strings.TrimSpace(getFirstValueOfDelimitedList(hostValue, commaDelimiter)), `"`)
 				if host != "" {
 					return uriPrefix + host
 				}
@@ -161,6 +876,31 @@ func getForwardedHeaderFirstHostValueAsUri(headerValue string) string {
 	return ""
 }
 
+// headerPresent reports whether the header with the given name is present regardless of its value.
+func headerPresent(header http.Header, name string) bool {
+	for key := range header {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerValue returns the first value of the header with the given name.
+// Unlike http.Header.Get it also finds headers whose keys have not been canonicalized,
+// e.g. the lowercase field names used by HTTP/2 if the header map has been populated directly.
+func headerValue(header http.Header, name string) string {
+	if value := header.Get(name); value != "" {
+		return value
+	}
+	for key, values := range header {
+		if len(values) > 0 && strings.EqualFold(key, name) {
+			return values[0]
+		}
+	}
+	return ""
+}
+
 func getFirstValueOfDelimitedList(delimitedList string, delimiter string) string {
 	if delimitedList == "" {
 		return delimitedList
@@ -177,6 +917,80 @@ func SystemBaseUriFromCtx(ctx context.Context) (string, error) {
 	return systemBaseUri, nil
 }
 
+// Sources of the systemBaseUri as returned by SystemBaseUriSourceFromCtx.
+const (
+	// SystemBaseUriSourceHeader means that the systemBaseUri has been taken from the request,
+	// usually from the header x-dv-baseuri.
+	SystemBaseUriSourceHeader = "header"
+	// SystemBaseUriSourceDefault means that the request didn't contain a systemBaseUri so the defaultSystemBaseUri has been used.
+	SystemBaseUriSourceDefault = "default"
+	// SystemBaseUriSourceUnset means that there is no systemBaseUri.
+	SystemBaseUriSourceUnset = "unset"
+)
+
+// SystemBaseUriSourceFromCtx reports where the systemBaseUri on the context comes from. It returns
+// SystemBaseUriSourceHeader, SystemBaseUriSourceDefault or SystemBaseUriSourceUnset.
+// SystemBaseUriSourceUnset is also returned if the context has not been created by the middleware.
+func SystemBaseUriSourceFromCtx(ctx context.Context) string {
+	if source, ok := ctx.Value(systemBaseUriSourceCtxKey).(string); ok {
+		return source
+	}
+	return SystemBaseUriSourceUnset
+}
+
+// UsedDefaultBaseUri reports whether the request didn't contain a systemBaseUri so the defaultSystemBaseUri
+// has been used. It is a shorthand for SystemBaseUriSourceFromCtx(ctx) == SystemBaseUriSourceDefault.
+func UsedDefaultBaseUri(ctx context.Context) bool {
+	return SystemBaseUriSourceFromCtx(ctx) == SystemBaseUriSourceDefault
+}
+
+func systemBaseUriSource(info TenantInfo, warnings []Warning) string {
+	if info.SystemBaseUri == "" {
+		return SystemBaseUriSourceUnset
+	}
+	for _, warning := range warnings {
+		if warning.Code == WarningDefaultBaseUriUsed {
+			return SystemBaseUriSourceDefault
+		}
+	}
+	return SystemBaseUriSourceHeader
+}
+
+// DefaultSystemBaseUriFromCtx reads the defaultSystemBaseUri the middleware has been configured with from the context.
+// It is available regardless of whether the request contained a systemBaseUri.
+func DefaultSystemBaseUriFromCtx(ctx context.Context) (string, error) {
+	defaultSystemBaseUri, ok := ctx.Value(defaultSystemBaseUriCtxKey).(string)
+	if !ok {
+		return "", errors.New("no DefaultSystemBaseUri on context")
+	}
+	return defaultSystemBaseUri, nil
+}
+
+// VerifiedAtFromCtx reads the time at which the middleware accepted the request from the context.
+// The time is taken from the clock configured by WithClock.
+func VerifiedAtFromCtx(ctx context.Context) (time.Time, bool) {
+	verifiedAt, ok := ctx.Value(verifiedAtCtxKey).(time.Time)
+	return verifiedAt, ok
+}
+
+// RawForwardedFromCtx reads the values of the headers Forwarded and X-Forwarded-Host from the context exactly
+// as they have been received by the middleware. This is meant for debugging the derivation of the
+// initiatorSystemBaseUri. The values are available even if the headers have been rewritten by WithNormalizeForwarded.
+// A value is empty if the header was not present.
+func RawForwardedFromCtx(ctx context.Context) (forwarded, xForwardedHost string) {
+	forwarded, _ = ctx.Value(rawForwardedCtxKey).(string)
+	xForwardedHost, _ = ctx.Value(rawXForwardedHostCtxKey).(string)
+	return forwarded, xForwardedHost
+}
+
+// SignatureVerifiedFromCtx reports whether the tenant information on the context has been verified by a signature.
+// Security sensitive handlers should refuse to act if it returns false, e.g. because the request didn't contain
+// any tenant headers or because the middleware runs in test mode (cf. WithTestModeRequireHeaders).
+func SignatureVerifiedFromCtx(ctx context.Context) bool {
+	verified, _ := ctx.Value(signatureVerifiedCtxKey).(bool)
+	return verified
+}
+
 // IdFromCtx reads the tenant id from the context.
 func IdFromCtx(ctx context.Context) (string, error) {
 	tenantId, ok := ctx.Value(tenantIdCtxKey).(string)
@@ -186,6 +1000,46 @@ func IdFromCtx(ctx context.Context) (string, error) {
 	return tenantId, nil
 }
 
+// IdFromCtxOrDefault reads the tenant id from the context and returns def if there is no tenant id on the context.
+// In contrast to the middleware, which uses the tenant id "0" for requests without tenant id, the fallback is chosen
+// by the caller. This is meant for code which doesn't necessarily run behind the middleware.
+func IdFromCtxOrDefault(ctx context.Context, def string) string {
+	if tenantId, err := IdFromCtx(ctx); err == nil {
+		return tenantId
+	}
+	return def
+}
+
+// IdIntFromCtx reads the tenant id from the context and parses it as an integer.
+// An error is returned if there is no tenant id on the context or if the tenant id is not numeric.
+func IdIntFromCtx(ctx context.Context) (int64, error) {
+	tenantId, err := IdFromCtx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseInt(tenantId, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("TenantId '%v' on context is not numeric", tenantId)
+	}
+	return id, nil
+}
+
+// TenantBucket assigns the tenant from the context to one of the given number of buckets, e.g. to roll out
+// a feature to a cohort of tenants. The bucket is in the range [0, buckets) and is derived from the SHA-256
+// hash of the tenant id. So it is the same for all requests of a tenant and independent of the instance.
+// An error is returned if there is no tenant id on the context or if buckets is not positive.
+func TenantBucket(ctx context.Context, buckets int) (int, error) {
+	if buckets <= 0 {
+		return 0, fmt.Errorf("number of buckets must be positive but is %v", buckets)
+	}
+	tenantId, err := IdFromCtx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	hash := sha256.Sum256([]byte(tenantId))
+	return int(binary.BigEndian.Uint64(hash[:8]) % uint64(buckets)), nil
+}
+
 // InitiatorSystemBaseUriFromCtx reads the uri of the initial requesting host from the context.
 func InitiatorSystemBaseUriFromCtx(ctx context.Context) (string, error) {
 	initiatorSystemBaseUri, ok := ctx.Value(initiatorSystemBaseUriCtxKey).(string)
@@ -195,6 +1049,47 @@ func InitiatorSystemBaseUriFromCtx(ctx context.Context) (string, error) {
 	return initiatorSystemBaseUri, nil
 }
 
+// InfoFromCtx reads the tenant information from the context. Values which are not on the context are empty.
+// The result can be serialized with encoding/json for structured logging (cf. TenantInfo.MarshalJSON).
+func InfoFromCtx(ctx context.Context) TenantInfo {
+	var info TenantInfo
+	info.TenantId, _ = IdFromCtx(ctx)
+	info.SystemBaseUri, _ = SystemBaseUriFromCtx(ctx)
+	info.InitiatorSystemBaseUri, _ = InitiatorSystemBaseUriFromCtx(ctx)
+	info.SignatureVerified = SignatureVerifiedFromCtx(ctx)
+	return info
+}
+
+// IsCrossSystemCall reports whether the request has been initiated by another system, that is the
+// initiatorSystemBaseUri is present and differs from the systemBaseUri. Both are compared in their
+// canonical form (cf. CanonicalBaseUri). An error is returned if there is no systemBaseUri on the context.
+func IsCrossSystemCall(ctx context.Context) (bool, error) {
+	systemBaseUri, err := SystemBaseUriFromCtx(ctx)
+	if err != nil {
+		return false, err
+	}
+	initiatorSystemBaseUri, err := InitiatorSystemBaseUriFromCtx(ctx)
+	if err != nil || initiatorSystemBaseUri == "" {
+		return false, nil
+	}
+	return CanonicalBaseUri(initiatorSystemBaseUri) != CanonicalBaseUri(systemBaseUri), nil
+}
+
+// Diagnose returns the error of each accessor for the tenant information on the context.
+// The keys of the map are "Id", "SystemBaseUri" and "InitiatorSystemBaseUri" and the values are
+// the errors returned by IdFromCtx, SystemBaseUriFromCtx and InitiatorSystemBaseUriFromCtx.
+// A nil value means that the information is present. This is meant for logging the state of the context.
+func Diagnose(ctx context.Context) map[string]error {
+	_, idErr := IdFromCtx(ctx)
+	_, systemBaseUriErr := SystemBaseUriFromCtx(ctx)
+	_, initiatorSystemBaseUriErr := InitiatorSystemBaseUriFromCtx(ctx)
+	return map[string]error{
+		"Id":                     idErr,
+		"SystemBaseUri":          systemBaseUriErr,
+		"InitiatorSystemBaseUri": initiatorSystemBaseUriErr,
+	}
+}
+
 // SetId returns a new context.Context with the given tenantId
 func SetId(ctx context.Context, tenantId string) context.Context {
 	return context.WithValue(ctx, tenantIdCtxKey, tenantId)
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -65,6 +66,46 @@ func TestNoBaseUriHeaderAndDefaultBaseUri_UsesDefaultBaseUri(t *testing.T) {
 	}
 }
 
+func TestNoBaseUriHeaderAndDefaultBaseUri_AddsDefaultBaseUriToContext(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var defaultFromCtx string
+	var errorReadingDefault error
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		defaultFromCtx, errorReadingDefault = tenant.DefaultSystemBaseUriFromCtx(r.Context())
+	})
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx(defaultSystemBaseUri, signatureKey, logSpy.logError)(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	if errorReadingDefault != nil {
+		t.Fatal(errorReadingDefault)
+	}
+	if defaultFromCtx != defaultSystemBaseUri {
+		t.Errorf("got wrong defaultSystemBaseUri from context: got %v want %v", defaultFromCtx, defaultSystemBaseUri)
+	}
+}
+
+func TestEmptyDefaultBaseUri_DoesntAddDefaultBaseUriToContext(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errorReadingDefault error
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, errorReadingDefault = tenant.DefaultSystemBaseUriFromCtx(r.Context())
+	})
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError)(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	if errorReadingDefault == nil {
+		t.Error("expected error while reading defaultSystemBaseUri from context")
+	}
+}
+
 func TestBaseUriHeaderAndDefaultBaseUri_UsesHeader(t *testing.T) {
 	req, err := http.NewRequest("GET", "/myresource/sub", nil)
 	if err != nil {
@@ -234,6 +275,155 @@ func TestInitiatorSystemBaseUriHeader_UsesXForwardedHeaderMultipleHosts(t *testi
 	}
 }
 
+func TestInitiatorSystemBaseUriHeader_UsesLowercaseForwardedHeaderKey(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// simulate HTTP/2 field names which have not been canonicalized
+	req.Header["forwarded"] = []string{"for=192.0.2.60; Host=forwarded.example.com"}
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertInitiatorSystemBaseUriIs(uriPrefix + "forwarded.example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInitiatorSystemBaseUriHeader_UsesLowercaseXForwardedHostHeaderKey(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// simulate HTTP/2 field names which have not been canonicalized
+	req.Header["x-forwarded-host"] = []string{"xforwarded.example.com"}
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertInitiatorSystemBaseUriIs(uriPrefix + "xforwarded.example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLowercaseTenantHeaderKeys_AreVerified(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const systemBaseUriFromHeader = "https://sample.example.com"
+	const tenantIdFromHeader = "a12be5"
+	req.Header["x-dv-baseuri"] = []string{systemBaseUriFromHeader}
+	req.Header["x-dv-tenant-id"] = []string{tenantIdFromHeader}
+	req.Header["x-dv-sig-1"] = []string{base64Signature(systemBaseUriFromHeader+tenantIdFromHeader, signatureKey)}
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs(tenantIdFromHeader); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestForwardedSignedMessage_ReturnsRawForwardedHeader(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const forwardedHeaderValue = "host=forwarded.example.com,secondhost.example.com"
+	req.Header.Set(forwardedHeader, forwardedHeaderValue)
+	req.Header.Set(xForwardedHostHeader, "xforwarded.example.com")
+
+	if m := tenant.ForwardedSignedMessage(req); m != forwardedHeaderValue {
+		t.Errorf("got wrong signed message: got %v want %v", m, forwardedHeaderValue)
+	}
+}
+
+func TestForwardedSignedMessage_ReturnsRawXForwardedHostHeaderIfNoForwardedHeader(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const xForwardedHostValue = "xforwarded.example.com,secondhost.example.com"
+	req.Header.Set(xForwardedHostHeader, xForwardedHostValue)
+
+	if m := tenant.ForwardedSignedMessage(req); m != xForwardedHostValue {
+		t.Errorf("got wrong signed message: got %v want %v", m, xForwardedHostValue)
+	}
+}
+
+func TestForwardedHeaderSignedWithWrongMessage_Returns403(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(forwardedHeader, "host=forwarded.example.com")
+	req.Header.Set(signatureHeader, base64Signature("forwarded.example.com", signatureKey))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if err := logSpy.assertLogContains("signature"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInitiatorSystemBaseUriHeader_IgnoresMalformedForwardedHeaders(t *testing.T) {
+	testCases := []struct {
+		header string
+		value  string
+	}{
+		{forwardedHeader, "host="},
+		{forwardedHeader, ";;;"},
+		{forwardedHeader, "host"},
+		{forwardedHeader, `host=""`},
+		{forwardedHeader, `host=", host=x"`},
+		{forwardedHeader, "host=bad host.example.com"},
+		{forwardedHeader, "host=/path"},
+		{forwardedHeader, "=;=,=;host=,"},
+		{xForwardedHostHeader, ","},
+		{xForwardedHostHeader, " , initiator.example.com"},
+		{xForwardedHostHeader, "initiator.example.com/path"},
+		{xForwardedHostHeader, "user@initiator.example.com"},
+	}
+	for _, tc := range testCases {
+		req := signedRequest(t, "https://sample.example.com", "a12be5")
+		req.Header.Set(tc.header, tc.value)
+		handlerSpy := handlerSpy{}
+		responseSpy := responseSpy{httptest.NewRecorder()}
+
+		tenant.AddToCtx("", signatureKey, nil)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+		if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+			t.Errorf("%v '%v': %v", tc.header, tc.value, err)
+		}
+		if err := handlerSpy.assertInitiatorSystemBaseUriIs("https://sample.example.com"); err != nil {
+			t.Errorf("%v '%v': %v", tc.header, tc.value, err)
+		}
+	}
+}
+
 func TestInitiatorSystemBaseUriHeader_EmptyForwardedHeadersNoSystemBaseUri(t *testing.T) {
 	req, err := http.NewRequest("GET", "/myresource/sub", nil)
 	if err != nil {
@@ -277,6 +467,49 @@ func TestInitiatorSystemBaseUriHeader_EmptyForwardedHeadersWithSystemBaseUri(t *
 	}
 }
 
+func TestInitiatorSystemBaseUriHeader_EmptyForwardedHeadersAndFallbackOn(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithInitiatorFallbackToSystemBaseUri(true))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertInitiatorSystemBaseUriIs("https://sample.example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInitiatorSystemBaseUriHeader_EmptyForwardedHeadersAndFallbackOff(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx(defaultSystemBaseUri, signatureKey, nil, tenant.WithInitiatorFallbackToSystemBaseUri(false))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertErrorReadingInitiatorSystemBaseUri(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInitiatorSystemBaseUriHeader_ForwardedHeaderAndFallbackOff(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(xForwardedHostHeader, "initiator.example.com")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithInitiatorFallbackToSystemBaseUri(false))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := handlerSpy.assertInitiatorSystemBaseUriIs("https://initiator.example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestInitiatorSystemBaseUriHeader_EmptyForwardedHeadersWithDefaultSystemBaseUri(t *testing.T) {
 	req, err := http.NewRequest("GET", "/myresource/sub", nil)
 	if err != nil {
@@ -546,6 +779,105 @@ func TestHeadersAndNoSignatureSecretKey_Returns500(t *testing.T) {
 	}
 }
 
+func TestBaseUriHeaderWithTrailingSlash_VerifiesRawValueAndStoresNormalizedValue(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const systemBaseUriFromHeader = "https://sample.example.com/"
+	const tenantIdFromHeader = "a12be5"
+	req.Header.Set(systemBaseUriHeader, systemBaseUriFromHeader)
+	req.Header.Set(tenantIdHeader, tenantIdFromHeader)
+	req.Header.Set(signatureHeader, base64Signature(systemBaseUriFromHeader+tenantIdFromHeader, signatureKey))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertBaseUriIs("https://sample.example.com"); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertInitiatorSystemBaseUriIs("https://sample.example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBaseUriHeaderWithTrailingSlashSignedWithoutSlash_Returns403(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const tenantIdFromHeader = "a12be5"
+	req.Header.Set(systemBaseUriHeader, "https://sample.example.com/")
+	req.Header.Set(tenantIdHeader, tenantIdFromHeader)
+	req.Header.Set(signatureHeader, base64Signature("https://sample.example.com"+tenantIdFromHeader, signatureKey))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError)(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestKeyEnvWithTwoKeys_AcceptsSignatureOfEitherKey(t *testing.T) {
+	rotationKey := []byte{167, 219, 144, 209, 189, 1, 178, 73, 139, 47, 21, 236, 142, 56, 71, 245, 43, 188, 163, 52, 239, 102, 94, 153, 255, 159, 199, 149, 163, 145, 161, 24}
+	envValue := base64.StdEncoding.EncodeToString(signatureKey) + "\n\n" + base64.StdEncoding.EncodeToString(rotationKey) + "\n"
+
+	for _, key := range [][]byte{signatureKey, rotationKey} {
+		req, err := http.NewRequest("GET", "/myresource/sub", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		const systemBaseUriFromHeader = "https://sample.example.com"
+		req.Header.Set(systemBaseUriHeader, systemBaseUriFromHeader)
+		const tenantIdFromHeader = "a12be5"
+		req.Header.Set(tenantIdHeader, tenantIdFromHeader)
+		req.Header.Set(signatureHeader, base64Signature(systemBaseUriFromHeader+tenantIdFromHeader, key))
+		handlerSpy := handlerSpy{}
+		responseSpy := responseSpy{httptest.NewRecorder()}
+		logSpy := loggerSpy{}
+
+		addToCtx, err := tenant.AddToCtxWithKeyEnv("", envValue, logSpy.logError)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addToCtx(&handlerSpy).ServeHTTP(responseSpy, req)
+
+		if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+			t.Error(err)
+		}
+		if err := handlerSpy.assertTenantIdIs(tenantIdFromHeader); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestKeyEnvWithNoneBase64Line_ReturnsError(t *testing.T) {
+	envValue := base64.StdEncoding.EncodeToString(signatureKey) + "\nabc+(9-!"
+	logSpy := loggerSpy{}
+
+	_, err := tenant.AddToCtxWithKeyEnv("", envValue, logSpy.logError)
+
+	if err == nil {
+		t.Error("expected error for none base64 key")
+	}
+}
+
+func TestKeyEnvWithoutKeys_ReturnsError(t *testing.T) {
+	for _, envValue := range []string{"", "\n \n\t\n"} {
+		if _, err := tenant.AddToCtxWithKeyEnv("", envValue, nil); err == nil {
+			t.Errorf("expected error for key env value '%v' without keys", envValue)
+		}
+	}
+}
+
 func TestNoIdOnContext_SetId_ReturnsContextWithId(t *testing.T) {
 	ctx := tenant.SetId(context.Background(), "123ABC")
 	if id, _ := tenant.IdFromCtx(ctx); id != "123ABC" {
@@ -561,6 +893,215 @@ func TestIdOnContext_SetId_ReturnsContextWithNewId(t *testing.T) {
 	}
 }
 
+func TestNumericIdOnContext_IdIntFromCtx_ReturnsId(t *testing.T) {
+	ctx := tenant.SetId(context.Background(), "4711")
+	id, err := tenant.IdIntFromCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 4711 {
+		t.Errorf("got wrong tenantId from context: got %v want %v", id, 4711)
+	}
+}
+
+func TestAlphanumericIdOnContext_IdIntFromCtx_ReturnsError(t *testing.T) {
+	ctx := tenant.SetId(context.Background(), "a12be5")
+	_, err := tenant.IdIntFromCtx(ctx)
+	if err == nil {
+		t.Fatal("expected error for alphanumeric tenantId")
+	}
+	if !strings.Contains(err.Error(), "a12be5") || !strings.Contains(err.Error(), "numeric") {
+		t.Errorf("expected descriptive error but got '%v'", err)
+	}
+}
+
+func TestSameTenant_TenantBucket_ReturnsStableBucket(t *testing.T) {
+	ctx := tenant.SetId(context.Background(), "a12be5")
+	first, err := tenant.TenantBucket(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		bucket, err := tenant.TenantBucket(tenant.SetId(context.Background(), "a12be5"), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bucket != first {
+			t.Errorf("got different buckets for the same tenant: got %v want %v", bucket, first)
+		}
+	}
+}
+
+func TestManyTenants_TenantBucket_DistributesTenants(t *testing.T) {
+	const buckets, tenants = 10, 10000
+	counts := make([]int, buckets)
+	for i := 0; i < tenants; i++ {
+		bucket, err := tenant.TenantBucket(tenant.SetId(context.Background(), strconv.Itoa(i)), buckets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bucket < 0 || bucket >= buckets {
+			t.Fatalf("bucket %v is out of range [0, %v)", bucket, buckets)
+		}
+		counts[bucket]++
+	}
+	for bucket, count := range counts {
+		// each bucket is expected to contain 1000 tenants
+		if count < 850 || count > 1150 {
+			t.Errorf("bucket %v contains %v of %v tenants", bucket, count, tenants)
+		}
+	}
+}
+
+func TestInvalidInput_TenantBucket_ReturnsError(t *testing.T) {
+	if _, err := tenant.TenantBucket(context.Background(), 10); err == nil {
+		t.Error("expected an error if there is no tenant id on the context")
+	}
+	if _, err := tenant.TenantBucket(tenant.SetId(context.Background(), "a12be5"), 0); err == nil {
+		t.Error("expected an error for zero buckets")
+	}
+}
+
+func TestSystemBaseUriSourceFromCtx(t *testing.T) {
+	unsignedRequest, _ := http.NewRequest("GET", "/myresource/sub", nil)
+	testCases := []struct {
+		name                 string
+		req                  *http.Request
+		defaultSystemBaseUri string
+		source               string
+	}{
+		{"header present", signedRequest(t, "https://sample.example.com", "a12be5"), defaultSystemBaseUri, tenant.SystemBaseUriSourceHeader},
+		{"default used", unsignedRequest, defaultSystemBaseUri, tenant.SystemBaseUriSourceDefault},
+		{"neither", unsignedRequest, "", tenant.SystemBaseUriSourceUnset},
+	}
+	for _, tc := range testCases {
+		var source string
+		next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			source = tenant.SystemBaseUriSourceFromCtx(r.Context())
+		})
+
+		tenant.AddToCtx(tc.defaultSystemBaseUri, signatureKey, nil)(next).ServeHTTP(httptest.NewRecorder(), tc.req)
+
+		if source != tc.source {
+			t.Errorf("%v: got wrong source: got %v want %v", tc.name, source, tc.source)
+		}
+	}
+}
+
+func TestContextNotCreatedByMiddleware_SystemBaseUriSourceFromCtx_ReturnsUnset(t *testing.T) {
+	if source := tenant.SystemBaseUriSourceFromCtx(context.Background()); source != tenant.SystemBaseUriSourceUnset {
+		t.Errorf("got wrong source: got %v want %v", source, tenant.SystemBaseUriSourceUnset)
+	}
+}
+
+func TestUsedDefaultBaseUri(t *testing.T) {
+	unsignedRequest, _ := http.NewRequest("GET", "/myresource/sub", nil)
+	testCases := []struct {
+		name                 string
+		req                  *http.Request
+		defaultSystemBaseUri string
+		want                 bool
+	}{
+		{"header present", signedRequest(t, "https://sample.example.com", "a12be5"), defaultSystemBaseUri, false},
+		{"header absent with default", unsignedRequest, defaultSystemBaseUri, true},
+		{"header absent without default", unsignedRequest, "", false},
+	}
+	for _, tc := range testCases {
+		var usedDefault bool
+		next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			usedDefault = tenant.UsedDefaultBaseUri(r.Context())
+		})
+
+		tenant.AddToCtx(tc.defaultSystemBaseUri, signatureKey, nil)(next).ServeHTTP(httptest.NewRecorder(), tc.req)
+
+		if usedDefault != tc.want {
+			t.Errorf("%v: got wrong result: got %v want %v", tc.name, usedDefault, tc.want)
+		}
+	}
+}
+
+func TestTenantIdOnContext_IdFromCtxOrDefault_ReturnsTenantId(t *testing.T) {
+	ctx := tenant.SetId(context.Background(), "a12be5")
+
+	if id := tenant.IdFromCtxOrDefault(ctx, "fallback"); id != "a12be5" {
+		t.Errorf("got wrong tenant id: got %v want %v", id, "a12be5")
+	}
+}
+
+func TestNoTenantIdOnContext_IdFromCtxOrDefault_ReturnsDefault(t *testing.T) {
+	if id := tenant.IdFromCtxOrDefault(context.Background(), "fallback"); id != "fallback" {
+		t.Errorf("got wrong tenant id: got %v want %v", id, "fallback")
+	}
+}
+
+func TestPartiallyPopulatedContext_Diagnose_ReportsMissingValues(t *testing.T) {
+	ctx := tenant.SetId(context.Background(), "a12be5")
+
+	diagnosis := tenant.Diagnose(ctx)
+
+	if len(diagnosis) != 3 {
+		t.Errorf("got wrong number of entries: got %v want %v", len(diagnosis), 3)
+	}
+	if err := diagnosis["Id"]; err != nil {
+		t.Errorf("expected no error for Id but got '%v'", err)
+	}
+	if err := diagnosis["SystemBaseUri"]; err == nil {
+		t.Error("expected error for SystemBaseUri")
+	}
+	if err := diagnosis["InitiatorSystemBaseUri"]; err == nil {
+		t.Error("expected error for InitiatorSystemBaseUri")
+	}
+}
+
+func TestSameInitiatorAndSystemBaseUri_IsCrossSystemCall_ReturnsFalse(t *testing.T) {
+	ctx := tenant.SetSystemBaseUri(context.Background(), "https://xyz.example.com")
+	ctx = tenant.SetInitiatorSystemBaseUri(ctx, "HTTPS://XYZ.example.com:443")
+
+	crossSystemCall, err := tenant.IsCrossSystemCall(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crossSystemCall {
+		t.Error("expected no cross system call")
+	}
+}
+
+func TestDifferentInitiatorAndSystemBaseUri_IsCrossSystemCall_ReturnsTrue(t *testing.T) {
+	ctx := tenant.SetSystemBaseUri(context.Background(), "https://xyz.example.com")
+	ctx = tenant.SetInitiatorSystemBaseUri(ctx, "https://abc.example.com")
+
+	crossSystemCall, err := tenant.IsCrossSystemCall(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !crossSystemCall {
+		t.Error("expected cross system call")
+	}
+}
+
+func TestEmptyInitiatorSystemBaseUri_IsCrossSystemCall_ReturnsFalse(t *testing.T) {
+	ctx := tenant.SetSystemBaseUri(context.Background(), "https://xyz.example.com")
+
+	crossSystemCall, err := tenant.IsCrossSystemCall(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crossSystemCall {
+		t.Error("expected no cross system call")
+	}
+}
+
+func TestNoSystemBaseUri_IsCrossSystemCall_ReturnsError(t *testing.T) {
+	ctx := tenant.SetInitiatorSystemBaseUri(context.Background(), "https://abc.example.com")
+
+	if _, err := tenant.IsCrossSystemCall(ctx); err == nil {
+		t.Error("expected error")
+	}
+}
+
 func TestSystemBaseUriOnContext_SetSystemBaseUri_ReturnsContextWithSystemBaseUri(t *testing.T) {
 	ctx := tenant.SetSystemBaseUri(context.Background(), "https://xyz.example.com")
 	if u, _ := tenant.SystemBaseUriFromCtx(ctx); u != "https://xyz.example.com" {
@@ -652,7 +1193,7 @@ func (spy *handlerSpy) assertErrorReadingTenantId() error {
 }
 
 func (spy *handlerSpy) assertErrorReadingInitiatorSystemBaseUri() error {
-	if spy.errorReadingTenantId == nil {
+	if spy.errorReadingInitiatorSystemBaseUri == nil {
 		return fmt.Errorf("expected error while reading initiatorSystembaseUri from context")
 	}
 	return nil
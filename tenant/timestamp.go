@@ -0,0 +1,146 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultReplayWindow is the replay window which is used if WithReplayWindow is not used.
+const defaultReplayWindow = 5 * time.Minute
+
+// WithTimestampInMessage requires the header with the given name to contain the time at which the request
+// has been signed as Unix time in seconds, e.g. "1700000000". The time is formatted in UTC according to
+// layout, e.g. time.RFC3339, and appended to the signed message like the platform does when it signs the request
+// (use SignRequest with the same option to sign such requests).
+//
+// Requests without a valid timestamp or whose timestamp differs from the time of the clock configured by WithClock
+// by more than the replay window (cf. WithReplayWindow) are rejected with status code 403. For a JWT
+// (cf. WithJWTSource) the claim iat is checked instead of the header; JWTs without it are rejected with status
+// code 401. Cookies (cf. WithCookieSource) aren't checked because they are reused for a whole session.
+func WithTimestampInMessage(headerName, layout string) Option {
+	return func(v *Verifier) error {
+		if headerName == "" {
+			return errors.New("timestamp header name must not be empty")
+		}
+		if layout == "" {
+			return errors.New("timestamp layout must not be empty")
+		}
+		v.timestampHeader = headerName
+		v.timestampLayout = layout
+		return nil
+	}
+}
+
+// WithReplayWindow sets the maximum difference between the timestamp of a request and the current time
+// which is accepted if WithTimestampInMessage is used. It is also the time for which nonces are recorded
+// if WithNonceStore is used. Defaults to 5 minutes.
+func WithReplayWindow(window time.Duration) Option {
+	return func(v *Verifier) error {
+		if window <= 0 {
+			return fmt.Errorf("replay window must be positive but is %v", window)
+		}
+		v.replayWindow = window
+		return nil
+	}
+}
+
+// WithFutureSkewTolerance sets the maximum time by which the timestamp of a request may be ahead of the clock
+// configured by WithClock if WithTimestampInMessage is used. Requests with a timestamp further in the future are
+// rejected with status code 403 even if they are within the replay window. So signatures created by clients whose
+// clock is ahead can't be replayed for longer than the replay window.
+func WithFutureSkewTolerance(tolerance time.Duration) Option {
+	return func(v *Verifier) error {
+		if tolerance <= 0 {
+			return fmt.Errorf("future skew tolerance must be positive but is %v", tolerance)
+		}
+		v.futureSkewTolerance = tolerance
+		return nil
+	}
+}
+
+// signedTimestamp returns the value of the timestamp header formatted according to the layout of
+// WithTimestampInMessage. The value of the header is returned unchanged if it isn't a valid Unix time,
+// so that the signature doesn't match; the request is rejected by headerTimestamp anyway.
+func (v *Verifier) signedTimestamp(req *http.Request) string {
+	value := headerValue(req.Header, v.timestampHeader)
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return value
+	}
+	return time.Unix(seconds, 0).UTC().Format(v.timestampLayout)
+}
+
+// headerTimestamp returns the timestamp of the request and rejects requests whose timestamp is missing, outside
+// the replay window or too far in the future.
+func (v *Verifier) headerTimestamp(req *http.Request) (time.Time, *verificationError) {
+	value := headerValue(req.Header, v.timestampHeader)
+	if value == "" {
+		return time.Time{}, &verificationError{http.StatusForbidden, fmt.Sprintf("timestamp header '%v' is missing", v.timestampHeader), nil}
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, &verificationError{http.StatusForbidden, fmt.Sprintf("parsing timestamp header '%v' with value '%v' as Unix time because: %v", v.timestampHeader, value, err), nil}
+	}
+	timestamp := time.Unix(seconds, 0)
+	if vErr := v.checkTimestamp(timestamp); vErr != nil {
+		return time.Time{}, vErr
+	}
+	return timestamp, nil
+}
+
+// checkTimestamp rejects timestamps which are outside the replay window or too far in the future.
+func (v *Verifier) checkTimestamp(timestamp time.Time) *verificationError {
+	if v.futureSkewTolerance > 0 && timestamp.Sub(v.now()) > v.futureSkewTolerance {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("future timestamp %v is more than %v ahead", timestamp.UTC(), v.futureSkewTolerance), nil}
+	}
+	if skew := v.now().Sub(timestamp); skew > v.replayWindow || skew < -v.replayWindow {
+		return &verificationError{http.StatusForbidden, fmt.Sprintf("timestamp %v is outside of the replay window of %v", timestamp.UTC(), v.replayWindow), nil}
+	}
+	return nil
+}
+
+// checkReplayProtection checks the signed expiry and timestamp of the request and adds them and the nonce of the
+// request to claims. The headers are only read for requests with tenant headers because only their signature
+// covers them. For a JWT the claims exp, iat and jti are used instead, whereas a cookie contains an expiry in its
+// signed value which has already been checked by validateCookie.
+func (v *Verifier) checkReplayProtection(req *http.Request, claims *sourceClaims) *verificationError {
+	switch claims.source {
+	case sourceHeaders:
+		if v.signedExpiryHeader != "" {
+			expiry, vErr := v.signedExpiry(req)
+			if vErr != nil {
+				return vErr
+			}
+			claims.expiry = expiry
+		}
+		if v.timestampHeader != "" {
+			timestamp, vErr := v.headerTimestamp(req)
+			if vErr != nil {
+				return vErr
+			}
+			claims.issuedAt = timestamp
+		}
+		if v.nonceStore != nil {
+			claims.nonce = headerValue(req.Header, nonceHeader)
+		}
+	case sourceJWT:
+		if v.signedExpiryHeader != "" && claims.expiry.IsZero() {
+			return &verificationError{http.StatusUnauthorized, "validating JWT because: claim exp is missing", nil}
+		}
+		if v.timestampHeader != "" {
+			if claims.issuedAt.IsZero() {
+				return &verificationError{http.StatusUnauthorized, "validating JWT because: claim iat is missing", nil}
+			}
+			if vErr := v.checkTimestamp(claims.issuedAt); vErr != nil {
+				return vErr
+			}
+		}
+		if v.nonceStore != nil && claims.nonce == "" {
+			return &verificationError{http.StatusUnauthorized, "validating JWT because: claim jti is missing", nil}
+		}
+	}
+	return nil
+}
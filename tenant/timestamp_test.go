@@ -0,0 +1,135 @@
+package tenant_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+const timestampHeader = "x-dv-timestamp"
+
+func TestTimestampWithinReplayWindow_TimestampInMessage_Returns200(t *testing.T) {
+	timestamp := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+	req := timestampedRequest(t, timestamp)
+	req.Header.Set(signatureHeader, base64Signature("https://sample.example.coma12be52023-11-14T22:13:20Z", signatureKey))
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	clock := func() time.Time { return timestamp.Add(time.Minute) }
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithTimestampInMessage(timestampHeader, time.RFC3339), tenant.WithClock(clock))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSkewedTimestamp_TimestampInMessage_Returns403(t *testing.T) {
+	for name, skew := range map[string]time.Duration{"past": -10 * time.Minute, "future": 10 * time.Minute} {
+		t.Run(name, func(t *testing.T) {
+			timestamp := time.Now().Add(skew)
+			req := timestampedRequest(t, timestamp)
+			if err := tenant.SignRequest(req, signatureKey, tenant.WithTimestampInMessage(timestampHeader, time.RFC3339)); err != nil {
+				t.Fatal(err)
+			}
+			handlerSpy := handlerSpy{}
+			responseSpy := responseSpy{httptest.NewRecorder()}
+
+			tenant.AddToCtx("", signatureKey, nil, tenant.WithTimestampInMessage(timestampHeader, time.RFC3339))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+			if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+				t.Error(err)
+			}
+			if handlerSpy.hasBeenCalled {
+				t.Error("inner handler should not have been called")
+			}
+		})
+	}
+}
+
+func TestSkewedTimestampWithinReplayWindow_ReplayWindow_Returns200(t *testing.T) {
+	req := timestampedRequest(t, time.Now().Add(-10*time.Minute))
+	if err := tenant.SignRequest(req, signatureKey, tenant.WithTimestampInMessage(timestampHeader, time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithTimestampInMessage(timestampHeader, time.RFC3339), tenant.WithReplayWindow(time.Hour))(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTimestampFormattedWithOtherLayout_TimestampInMessage_Returns403(t *testing.T) {
+	timestamp := time.Now()
+	req := timestampedRequest(t, timestamp)
+	if err := tenant.SignRequest(req, signatureKey, tenant.WithTimestampInMessage(timestampHeader, time.RFC1123)); err != nil {
+		t.Fatal(err)
+	}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil, tenant.WithTimestampInMessage(timestampHeader, time.RFC3339))(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFutureTimestamp_FutureSkewTolerance_Returns403(t *testing.T) {
+	req := timestampedRequest(t, time.Now().Add(10*time.Minute))
+	if err := tenant.SignRequest(req, signatureKey, tenant.WithTimestampInMessage(timestampHeader, time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+	logSpy := loggerSpy{}
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, logSpy.logError, tenant.WithTimestampInMessage(timestampHeader, time.RFC3339), tenant.WithReplayWindow(time.Hour), tenant.WithFutureSkewTolerance(time.Minute))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+	if err := logSpy.assertLogContains("future timestamp"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTimestampsWithinTolerance_FutureSkewTolerance_Returns200(t *testing.T) {
+	for name, skew := range map[string]time.Duration{"past": -10 * time.Minute, "future": 30 * time.Second} {
+		t.Run(name, func(t *testing.T) {
+			req := timestampedRequest(t, time.Now().Add(skew))
+			if err := tenant.SignRequest(req, signatureKey, tenant.WithTimestampInMessage(timestampHeader, time.RFC3339)); err != nil {
+				t.Fatal(err)
+			}
+			responseSpy := responseSpy{httptest.NewRecorder()}
+
+			tenant.AddToCtx("", signatureKey, nil, tenant.WithTimestampInMessage(timestampHeader, time.RFC3339), tenant.WithReplayWindow(time.Hour), tenant.WithFutureSkewTolerance(time.Minute))(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+			if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func timestampedRequest(t *testing.T, timestamp time.Time) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(systemBaseUriHeader, "https://sample.example.com")
+	req.Header.Set(tenantIdHeader, "a12be5")
+	req.Header.Set(timestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	return req
+}
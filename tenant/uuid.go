@@ -0,0 +1,76 @@
+package tenant
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+// UUID is a tenant id which is a UUID (RFC 4122). It has the same representation as the UUID types of common
+// UUID packages, e.g. it can be converted to a github.com/google/uuid UUID with uuid.UUID(id).
+type UUID [16]byte
+
+// String returns the UUID in its canonical form, e.g. "6ba7b810-9dad-11d1-80b4-00c04fd430c8".
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// parseUUID parses s in the canonical form of a UUID. The hex digits are case-insensitive.
+func parseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("'%v' is not a UUID in the form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx", s)
+	}
+	digits := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(u[:], []byte(digits)); err != nil {
+		return UUID{}, fmt.Errorf("'%v' is not a UUID because: %v", s, err)
+	}
+	return u, nil
+}
+
+// WithUUIDTenantId requires the tenant id of each request to be a UUID in its canonical form, e.g.
+// "6ba7b810-9dad-11d1-80b4-00c04fd430c8". Requests with another tenant id are rejected with status code 400.
+// Requests without tenant id, which get the tenant id "0", are not affected. Use UUIDFromCtx to read the
+// tenant id as UUID.
+func WithUUIDTenantId() Option {
+	return func(v *Verifier) error {
+		v.uuidTenantId = true
+		return nil
+	}
+}
+
+// checkUUIDTenantId rejects the tenant id if it is not a UUID according to WithUUIDTenantId.
+func (v *Verifier) checkUUIDTenantId(tenantId string) *verificationError {
+	if !v.uuidTenantId || tenantId == "" {
+		return nil
+	}
+	if _, err := parseUUID(tenantId); err != nil {
+		return &verificationError{http.StatusBadRequest, fmt.Sprintf("malformed tenant id: %v", err), nil}
+	}
+	return nil
+}
+
+// UUIDFromCtx reads the tenant id from the context and parses it as UUID. An error is returned if there is
+// no tenant id on the context or if the tenant id is not a UUID in its canonical form.
+func UUIDFromCtx(ctx context.Context) (UUID, error) {
+	tenantId, err := IdFromCtx(ctx)
+	if err != nil {
+		return UUID{}, err
+	}
+	u, err := parseUUID(tenantId)
+	if err != nil {
+		return UUID{}, fmt.Errorf("reading TenantId from context as UUID because: %v", err)
+	}
+	return u, nil
+}
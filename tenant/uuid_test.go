@@ -0,0 +1,75 @@
+package tenant_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+const uuidTenantId = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+
+func TestUUIDTenantId_UUIDTenantId_AddsTenantToContext(t *testing.T) {
+	for _, tenantId := range []string{uuidTenantId, "6BA7B810-9DAD-11D1-80B4-00C04FD430C8"} {
+		req := signedRequest(t, "https://sample.example.com", tenantId)
+		handlerSpy := handlerSpy{}
+		responseSpy := responseSpy{httptest.NewRecorder()}
+
+		tenant.AddToCtx("", signatureKey, nil, tenant.WithUUIDTenantId())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+		if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+			t.Errorf("%v: %v", tenantId, err)
+		}
+		if err := handlerSpy.assertTenantIdIs(tenantId); err != nil {
+			t.Errorf("%v: %v", tenantId, err)
+		}
+	}
+}
+
+func TestMalformedTenantId_UUIDTenantId_Returns400(t *testing.T) {
+	for _, tenantId := range []string{"a12be5", "6ba7b810-9dad-11d1-80b4-00c04fd430c", "6ba7b810x9dad-11d1-80b4-00c04fd430c8", "6ba7b810-9dad-11d1-80b4-00c04fd430cg"} {
+		req := signedRequest(t, "https://sample.example.com", tenantId)
+		handlerSpy := handlerSpy{}
+		responseSpy := responseSpy{httptest.NewRecorder()}
+
+		tenant.AddToCtx("", signatureKey, nil, tenant.WithUUIDTenantId())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+		if err := responseSpy.assertStatusCodeIs(http.StatusBadRequest); err != nil {
+			t.Errorf("%v: %v", tenantId, err)
+		}
+		if handlerSpy.hasBeenCalled {
+			t.Errorf("%v: inner handler should not have been called", tenantId)
+		}
+	}
+}
+
+func TestUUIDFromCtx_ReturnsParsedTenantId(t *testing.T) {
+	ctx := tenant.SetId(context.Background(), "6BA7B810-9DAD-11D1-80B4-00C04FD430C8")
+
+	id, err := tenant.UUIDFromCtx(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := tenant.UUID{0x6b, 0xa7, 0xb8, 0x10, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+	if id != want {
+		t.Errorf("got wrong UUID: got %v want %v", id, want)
+	}
+	if id.String() != uuidTenantId {
+		t.Errorf("got wrong string: got %v want %v", id.String(), uuidTenantId)
+	}
+}
+
+func TestNoUUIDOnContext_UUIDFromCtx_ReturnsError(t *testing.T) {
+	for name, ctx := range map[string]context.Context{
+		"no tenant id":   context.Background(),
+		"no UUID":        tenant.SetId(context.Background(), "a12be5"),
+		"default tenant": tenant.SetId(context.Background(), "0"),
+	} {
+		if _, err := tenant.UUIDFromCtx(ctx); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// TenantInfo contains the tenant information of a request.
+type TenantInfo struct {
+	TenantId               string
+	SystemBaseUri          string
+	InitiatorSystemBaseUri string
+	// SignatureVerified reports whether the tenant information has been verified by a signature.
+	// It is false if the request has been accepted without verification, e.g. because it didn't contain
+	// any tenant headers or because WithTestModeRequireHeaders is used.
+	SignatureVerified bool
+}
+
+// MarshalJSON renders the tenant information compactly for structured logs. It contains the tenant id
+// and the hosts of the systemBaseUri and the initiatorSystemBaseUri. Empty values are omitted.
+// TenantInfo never contains signatures or keys so it is safe to log.
+func (i TenantInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		TenantId      string `json:"tenantId,omitempty"`
+		BaseUriHost   string `json:"baseUriHost,omitempty"`
+		InitiatorHost string `json:"initiatorHost,omitempty"`
+	}{
+		TenantId:      i.TenantId,
+		BaseUriHost:   hostOf(i.SystemBaseUri),
+		InitiatorHost: hostOf(i.InitiatorSystemBaseUri),
+	})
+}
+
+// hostOf returns the host of the given uri or the uri itself if it has no host.
+func hostOf(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" {
+		return u
+	}
+	return parsed.Host
+}
+
+// Warning codes describe conditions which are not fatal but should be logged.
+const (
+	// WarningDeprecatedSignatureVersion indicates that the request has been signed with an older
+	// signature version than the highest version accepted.
+	WarningDeprecatedSignatureVersion = "deprecated-signature-version"
+	// WarningDefaultBaseUriUsed indicates that the request didn't contain a systemBaseUri so
+	// the default systemBaseUri has been used.
+	WarningDefaultBaseUriUsed = "default-base-uri-used"
+)
+
+// Warning describes a non-fatal condition which has been detected during the validation of a request.
+type Warning struct {
+	Code    string
+	Message string
+}
+
+// ValidateRequest performs the same validation as the middleware returned by AddToCtx without
+// invoking a handler. It returns the tenant information of the request together with non-fatal warnings.
+//
+// Use WithDefaultSystemBaseUri to set the systemBaseUri which is used if the request doesn't contain one.
+// An error is returned if the request is invalid.
+func ValidateRequest(r *http.Request, signatureSecretKey []byte, options ...Option) (TenantInfo, []Warning, error) {
+	var signatureSecretKeys [][]byte
+	if signatureSecretKey != nil {
+		signatureSecretKeys = [][]byte{signatureSecretKey}
+	}
+	v, err := newVerifier("", signatureSecretKeys, nil, options)
+	if err != nil {
+		return TenantInfo{}, nil, err
+	}
+	info, warnings, _, vErr := v.validate(r)
+	if vErr != nil {
+		return TenantInfo{}, nil, vErr
+	}
+	return info, warnings, nil
+}
+
+// ValidateHeaders validates the tenant headers in h like ValidateRequest validates the headers of a request.
+// It can be used if only the headers are available, e.g. in proxies. The headers Forwarded and x-forwarded-host
+// are ignored, so the returned InitiatorSystemBaseUri is never taken from them.
+//
+// Options which require the body or the request line, i.e. body signatures, WithSignMethodAndPath,
+// WithSignQueryParams and WithSignHost, are not supported and an error is returned if they are used. The same
+// applies to WithSignForwardedChain because the forwarded headers are ignored. An error is also returned
+// if the headers are invalid.
+func ValidateHeaders(h http.Header, signatureSecretKey []byte, options ...Option) (TenantInfo, error) {
+	var signatureSecretKeys [][]byte
+	if signatureSecretKey != nil {
+		signatureSecretKeys = [][]byte{signatureSecretKey}
+	}
+	v, err := newVerifier("", signatureSecretKeys, nil, options)
+	if err != nil {
+		return TenantInfo{}, err
+	}
+	if v.bodySignature || v.formBodySignature || v.streamingBodySignature || v.signMethodAndPath || v.signQueryParams || v.signHost {
+		return TenantInfo{}, errors.New("body signatures and signatures of method, path, query and host can't be validated from headers only")
+	}
+	if v.signForwardedChain {
+		return TenantInfo{}, errors.New("signatures of the forwarded chain can't be validated because the forwarded headers are ignored")
+	}
+	header := h.Clone()
+	deleteForwardedHeaders(header)
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    &url.URL{Path: "/"},
+		Header: header,
+		Body:   http.NoBody,
+	}
+	info, _, _, vErr := v.validate(req)
+	if vErr != nil {
+		return TenantInfo{}, vErr
+	}
+	return info, nil
+}
@@ -0,0 +1,299 @@
+package tenant_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestV1SignatureOnMultiVersionVerifier_ReturnsDeprecatedSignatureVersionWarning(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+
+	info, warnings, err := tenant.ValidateRequest(req, signatureKey, tenant.WithSignatureVersions(1, 2))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.TenantId != "a12be5" || info.SystemBaseUri != "https://sample.example.com" {
+		t.Errorf("got wrong tenant info: %+v", info)
+	}
+	if !containsWarning(warnings, tenant.WarningDeprecatedSignatureVersion) {
+		t.Errorf("expected warning %v but got %+v", tenant.WarningDeprecatedSignatureVersion, warnings)
+	}
+	if containsWarning(warnings, tenant.WarningDefaultBaseUriUsed) {
+		t.Errorf("unexpected warning %v", tenant.WarningDefaultBaseUriUsed)
+	}
+}
+
+func TestV2Signature_ReturnsNoWarnings(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(systemBaseUriHeader, "https://sample.example.com")
+	req.Header.Set(tenantIdHeader, "a12be5")
+	req.Header.Set("x-dv-sig-2", base64Signature("https://sample.example.com\na12be5", signatureKey))
+
+	_, warnings, err := tenant.ValidateRequest(req, signatureKey, tenant.WithSignatureVersions(1, 2))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expected no warnings but got %+v", warnings)
+	}
+}
+
+func TestNoBaseUriHeader_ReturnsDefaultBaseUriUsedWarning(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, warnings, err := tenant.ValidateRequest(req, signatureKey, tenant.WithDefaultSystemBaseUri(defaultSystemBaseUri))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SystemBaseUri != defaultSystemBaseUri {
+		t.Errorf("got wrong systemBaseUri: got %v want %v", info.SystemBaseUri, defaultSystemBaseUri)
+	}
+	if !containsWarning(warnings, tenant.WarningDefaultBaseUriUsed) {
+		t.Errorf("expected warning %v but got %+v", tenant.WarningDefaultBaseUriUsed, warnings)
+	}
+}
+
+func TestInvalidSignature_ValidateRequest_ReturnsError(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(tenantIdHeader, "other")
+
+	if _, _, err := tenant.ValidateRequest(req, signatureKey); err == nil {
+		t.Error("expected error for invalid signature")
+	}
+}
+
+func containsWarning(warnings []tenant.Warning, code string) bool {
+	for _, w := range warnings {
+		if w.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSignatureWithVersionPrefix_IsVerified(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(signatureHeader, "v1="+req.Header.Get(signatureHeader))
+
+	info, _, err := tenant.ValidateRequest(req, signatureKey)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.TenantId != "a12be5" {
+		t.Errorf("got wrong tenantId: got %v want %v", info.TenantId, "a12be5")
+	}
+}
+
+func TestSignatureWithoutVersionPrefix_IsVerifiedAsV1(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+
+	if _, _, err := tenant.ValidateRequest(req, signatureKey, tenant.WithSignatureVersions(1, 2)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestV2PrefixInV1Header_IsVerifiedAsV2(t *testing.T) {
+	req, err := http.NewRequest("GET", "/myresource/sub", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(systemBaseUriHeader, "https://sample.example.com")
+	req.Header.Set(tenantIdHeader, "a12be5")
+	req.Header.Set(signatureHeader, "v2="+base64Signature("https://sample.example.com\na12be5", signatureKey))
+
+	_, warnings, err := tenant.ValidateRequest(req, signatureKey, tenant.WithSignatureVersions(2))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expected no warnings but got %+v", warnings)
+	}
+}
+
+func TestSignatureWithUnacceptedVersionPrefix_ReturnsError(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(signatureHeader, "v3="+req.Header.Get(signatureHeader))
+
+	if _, _, err := tenant.ValidateRequest(req, signatureKey); err == nil {
+		t.Error("expected error for unaccepted signature version")
+	}
+}
+
+func TestSignedMessage_ConcatenatesPresentValuesInCanonicalOrder(t *testing.T) {
+	const systemBaseUri = "https://sample.example.com"
+	const tenantId = "a12be5"
+	const initiator = "https://initiator.example.com"
+	for _, tc := range signedMessageTestCases(systemBaseUri, tenantId, initiator) {
+		if m := tenant.SignedMessage(tc.systemBaseUri, tc.tenantId, tc.initiator); m != tc.expected {
+			t.Errorf("got wrong signed message for %+v: got %v want %v", tc, m, tc.expected)
+		}
+	}
+}
+
+func TestSignedInitiator_VerifierAcceptsSignedMessageForAllCombinations(t *testing.T) {
+	const systemBaseUri = "https://sample.example.com"
+	const tenantId = "a12be5"
+	const initiatorHost = "initiator.example.com"
+	for _, tc := range signedMessageTestCases(systemBaseUri, tenantId, "https://"+initiatorHost) {
+		req, err := http.NewRequest("GET", "/myresource/sub", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.systemBaseUri != "" {
+			req.Header.Set(systemBaseUriHeader, tc.systemBaseUri)
+		}
+		if tc.tenantId != "" {
+			req.Header.Set(tenantIdHeader, tc.tenantId)
+		}
+		if tc.initiator != "" {
+			req.Header.Set(xForwardedHostHeader, initiatorHost)
+		}
+		req.Header.Set(signatureHeader, base64Signature(tenant.SignedMessage(tc.systemBaseUri, tc.tenantId, tc.initiator), signatureKey))
+
+		if _, _, err := tenant.ValidateRequest(req, signatureKey, tenant.WithSignedInitiator()); err != nil {
+			t.Errorf("expected valid signature for %+v but got '%v'", tc, err)
+		}
+
+		req.Header.Set(xForwardedHostHeader, "other.example.com")
+		if _, _, err := tenant.ValidateRequest(req, signatureKey, tenant.WithSignedInitiator()); err == nil {
+			t.Errorf("expected invalid signature for %+v with tampered initiator", tc)
+		}
+	}
+}
+
+type signedMessageTestCase struct {
+	systemBaseUri, tenantId, initiator string
+	expected                           string
+}
+
+func signedMessageTestCases(systemBaseUri, tenantId, initiator string) []signedMessageTestCase {
+	var testCases []signedMessageTestCase
+	for i := 0; i < 8; i++ {
+		var tc signedMessageTestCase
+		if i&1 != 0 {
+			tc.systemBaseUri = systemBaseUri
+		}
+		if i&2 != 0 {
+			tc.tenantId = tenantId
+		}
+		if i&4 != 0 {
+			tc.initiator = initiator
+		}
+		tc.expected = tc.systemBaseUri + tc.tenantId + tc.initiator
+		testCases = append(testCases, tc)
+	}
+	return testCases
+}
+
+func TestTenantInfoMarshalJSON_ContainsTenantIdAndHostsButNoSignature(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(xForwardedHostHeader, "initiator.example.com")
+	info, _, err := tenant.ValidateRequest(req, signatureKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serialized, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"tenantId":"a12be5","baseUriHost":"sample.example.com","initiatorHost":"initiator.example.com"}`
+	if string(serialized) != expected {
+		t.Errorf("got wrong JSON: got %v want %v", string(serialized), expected)
+	}
+	if strings.Contains(string(serialized), req.Header.Get(signatureHeader)) {
+		t.Error("JSON must not contain the signature")
+	}
+}
+
+func TestInfoFromCtx_ReturnsTenantInformationOnContext(t *testing.T) {
+	ctx := tenant.SetId(context.Background(), "a12be5")
+	ctx = tenant.SetSystemBaseUri(ctx, "https://sample.example.com")
+
+	info := tenant.InfoFromCtx(ctx)
+
+	expected := tenant.TenantInfo{TenantId: "a12be5", SystemBaseUri: "https://sample.example.com"}
+	if info != expected {
+		t.Errorf("got wrong tenant info: got %+v want %+v", info, expected)
+	}
+}
+
+func TestValidHeaders_ValidateHeaders_ReturnsTenantInfo(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(forwardedHeader, "host=initiator.example.com")
+
+	info, err := tenant.ValidateHeaders(req.Header, signatureKey)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := tenant.TenantInfo{TenantId: "a12be5", SystemBaseUri: "https://sample.example.com", InitiatorSystemBaseUri: "https://sample.example.com", SignatureVerified: true}
+	if info != want {
+		t.Errorf("got wrong tenant info: got %+v want %+v", info, want)
+	}
+}
+
+func TestLowercaseForwardedHeaders_ValidateHeaders_IgnoresForwardedHeaders(t *testing.T) {
+	header := signedRequest(t, "https://sample.example.com", "a12be5").Header
+	header["forwarded"] = []string{"host=initiator.example.com"}
+	header["x-forwarded-host"] = []string{"initiator.example.com"}
+
+	info, err := tenant.ValidateHeaders(header, signatureKey)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.InitiatorSystemBaseUri != "https://sample.example.com" {
+		t.Errorf("got wrong initiator: got %v want %v", info.InitiatorSystemBaseUri, "https://sample.example.com")
+	}
+	if _, ok := header["forwarded"]; !ok {
+		t.Error("headers of the caller should not have been modified")
+	}
+}
+
+func TestTamperedHeaders_ValidateHeaders_ReturnsInvalidSignatureError(t *testing.T) {
+	for name, tamper := range map[string]func(h http.Header){
+		"tenant id": func(h http.Header) { h.Set(tenantIdHeader, "other") },
+		"base uri":  func(h http.Header) { h.Set(systemBaseUriHeader, "https://evil.example.com") },
+		"signature": func(h http.Header) {
+			h.Set(signatureHeader, base64Signature("https://sample.example.coma12be5", []byte("other")))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			header := signedRequest(t, "https://sample.example.com", "a12be5").Header
+			tamper(header)
+
+			_, err := tenant.ValidateHeaders(header, signatureKey)
+
+			if !errors.Is(err, tenant.ErrInvalidSignature) {
+				t.Errorf("got wrong error: got %v want %v", err, tenant.ErrInvalidSignature)
+			}
+		})
+	}
+}
+
+func TestSignMethodAndPath_ValidateHeaders_ReturnsError(t *testing.T) {
+	header := signedRequest(t, "https://sample.example.com", "a12be5").Header
+
+	if _, err := tenant.ValidateHeaders(header, signatureKey, tenant.WithSignMethodAndPath()); err == nil {
+		t.Error("expected an error for an option which requires the request line")
+	}
+}
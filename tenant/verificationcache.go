@@ -0,0 +1,97 @@
+package tenant
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// WithVerificationCache caches the signatures of the last size accepted requests so that the signature of an
+// identical request, e.g. a retry, is not computed again. The cache is keyed by the signature together with the
+// complete signed message. So a cached signature is only accepted for exactly the same signed values.
+//
+// All other checks, e.g. of the expiry (cf. WithSignedExpiryHeader) or of the body signature, are performed
+// for every request and the tenant information is extracted from the request as usual. The cache is also keyed
+// by a fingerprint of the current HMAC keys, so a cached signature isn't accepted anymore once the keys change,
+// e.g. because a KeyProvider doesn't return a revoked key or the overlap of WithKeyRotation has ended.
+func WithVerificationCache(size int) Option {
+	return func(v *Verifier) error {
+		if size <= 0 {
+			return fmt.Errorf("verification cache size must be positive but is %v", size)
+		}
+		v.verificationCache = newVerificationCache(size)
+		return nil
+	}
+}
+
+// verificationCache is a LRU cache of accepted signatures. A nil cache never contains a signature.
+type verificationCache struct {
+	size int
+
+	mu      sync.Mutex // protects the following fields
+	order   *list.List // of keys, the most recently used key first
+	entries map[string]*list.Element
+}
+
+func newVerificationCache(size int) *verificationCache {
+	return &verificationCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// keySetFingerprint identifies the keys of scheme. Schemes other than HMAC schemes are configured once by
+// WithSignatureScheme, so their fingerprint is empty.
+func keySetFingerprint(scheme SignatureScheme) string {
+	hmacScheme, ok := scheme.(hmacScheme)
+	if !ok {
+		return ""
+	}
+	hash := sha256.New()
+	for _, key := range hmacScheme.keys {
+		hash.Write([]byte(strconv.Itoa(len(key)) + ":"))
+		hash.Write(key)
+	}
+	return string(hash.Sum(nil))
+}
+
+func verificationCacheKey(fingerprint string, version int, message string, signature []byte) string {
+	return fingerprint + "\x00" + strconv.Itoa(version) + "\x00" + string(signature) + "\x00" + message
+}
+
+// contains reports whether the signature has been accepted for the message before with the same keys.
+func (c *verificationCache) contains(fingerprint string, version int, message string, signature []byte) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[verificationCacheKey(fingerprint, version, message, signature)]
+	if ok {
+		c.order.MoveToFront(element)
+	}
+	return ok
+}
+
+// add records that the signature has been accepted for the message with the keys identified by fingerprint.
+func (c *verificationCache) add(fingerprint string, version int, message string, signature []byte) {
+	if c == nil {
+		return
+	}
+	key := verificationCacheKey(fingerprint, version, message, signature)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(key)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(string))
+	}
+}
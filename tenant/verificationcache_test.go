@@ -0,0 +1,74 @@
+package tenant_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestIdenticalRequests_VerificationCache_VerifiesSignatureOnceAndPopulatesContext(t *testing.T) {
+	scheme := countingScheme{SignatureScheme: tenant.HMACScheme(signatureKey)}
+	handler := tenant.AddToCtxWithVerifier("", &scheme, nil, tenant.WithVerificationCache(10))
+
+	for i := 0; i < 3; i++ {
+		req := signedRequest(t, "https://sample.example.com", "a12be5")
+		handlerSpy := handlerSpy{}
+		responseSpy := responseSpy{httptest.NewRecorder()}
+
+		handler(&handlerSpy).ServeHTTP(responseSpy, req)
+
+		if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+			t.Error(err)
+		}
+		if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+			t.Error(err)
+		}
+		if err := handlerSpy.assertBaseUriIs("https://sample.example.com"); err != nil {
+			t.Error(err)
+		}
+	}
+	if scheme.calls != 1 {
+		t.Errorf("got wrong number of signature verifications: got %v want %v", scheme.calls, 1)
+	}
+}
+
+func TestSameSignatureForOtherTenant_VerificationCache_Returns403(t *testing.T) {
+	scheme := countingScheme{SignatureScheme: tenant.HMACScheme(signatureKey)}
+	handler := tenant.AddToCtxWithVerifier("", &scheme, nil, tenant.WithVerificationCache(10))
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handler(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), req)
+
+	req.Header.Set(tenantIdHeader, "other")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	handler(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEvictedSignature_VerificationCache_VerifiesSignatureAgain(t *testing.T) {
+	scheme := countingScheme{SignatureScheme: tenant.HMACScheme(signatureKey)}
+	handler := tenant.AddToCtxWithVerifier("", &scheme, nil, tenant.WithVerificationCache(1))
+
+	for _, tenantId := range []string{"1", "2", "1"} {
+		handler(&handlerSpy{}).ServeHTTP(httptest.NewRecorder(), signedRequest(t, "https://sample.example.com", tenantId))
+	}
+
+	if scheme.calls != 3 {
+		t.Errorf("got wrong number of signature verifications: got %v want %v", scheme.calls, 3)
+	}
+}
+
+type countingScheme struct {
+	tenant.SignatureScheme
+	calls int
+}
+
+func (s *countingScheme) Verify(message, signature []byte) bool {
+	s.calls++
+	return s.SignatureScheme.Verify(message, signature)
+}
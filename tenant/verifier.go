@@ -0,0 +1,181 @@
+package tenant
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// NewVerifier creates a Verifier which is configured by the given options.
+//
+// In contrast to AddToCtx, which responds with status code 500 to every request if an option
+// is invalid, NewVerifier returns the error so that it can be handled when the app is wired.
+// Use WithSignatureSecretKeys, WithDefaultSystemBaseUri and WithLogger to configure what is passed
+// as arguments to AddToCtx.
+//
+// Example:
+//
+//	verifier, err := tenant.NewVerifier(tenant.WithSignatureSecretKeys(signatureSecretKey), tenant.WithLogger(log))
+//	if err != nil {
+//		return err
+//	}
+//	mux.Handle("/hello", verifier.Middleware(helloHandler()))
+func NewVerifier(options ...Option) (*Verifier, error) {
+	return newVerifier("", nil, nil, options)
+}
+
+// MustNewVerifier is like NewVerifier but panics if an option is invalid.
+func MustNewVerifier(options ...Option) *Verifier {
+	v, err := NewVerifier(options...)
+	if err != nil {
+		panic("tenant: configuring verifier because: " + err.Error())
+	}
+	return v
+}
+
+// Middleware returns a handler which verifies the tenant of each request and adds it to the
+// context before the next handler is invoked. It behaves like the middleware returned by AddToCtx.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return v.handler(next)
+}
+
+// DefaultSystemBaseUri returns the default systemBaseUri which has been configured with WithDefaultSystemBaseUri
+// or AddToCtx, e.g. for building links in background jobs which don't run within a request. It is normalized like
+// the value returned by DefaultSystemBaseUriFromCtx. An empty string is returned if no default is configured.
+func (v *Verifier) DefaultSystemBaseUri() string {
+	if v.defaultSystemBaseUri == "" {
+		return ""
+	}
+	return normalizeBaseUri(v.defaultSystemBaseUri)
+}
+
+// KeyFingerprint returns a fingerprint of the configured keys which allows to check whether two deployments
+// use the same keys without exposing them. The fingerprint of a key consists of the first 16 hex digits of
+// its SHA-256 hash. The fingerprints of several keys are sorted and joined by commas so that the order of
+// the keys doesn't matter. For an Ed25519Scheme the fingerprint of the public key is returned.
+//
+// An empty string is returned if no keys are configured or if the keys are provided by a KeyProvider
+// or a custom SignatureScheme, because they are not known in advance.
+func (v *Verifier) KeyFingerprint() string {
+	var keys [][]byte
+	switch {
+	case v.scheme != nil:
+		switch scheme := v.scheme.(type) {
+		case hmacScheme:
+			keys = scheme.keys
+		case ed25519Scheme:
+			keys = [][]byte{scheme.publicKey}
+		}
+	default:
+		keys, _ = v.keyProvider.(staticKeyProvider)
+	}
+	fingerprints := make([]string, 0, len(keys))
+	for _, key := range keys {
+		hash := sha256.Sum256(key)
+		fingerprints = append(fingerprints, hex.EncodeToString(hash[:8]))
+	}
+	sort.Strings(fingerprints)
+	return strings.Join(fingerprints, ",")
+}
+
+// minReadyKeyLength is the minimum length in bytes of the HMAC keys which Ready accepts.
+const minReadyKeyLength = 16
+
+// Ready returns nil if the verifier is able to verify signatures, i.e. if HMAC keys with at least 16 bytes,
+// another SignatureScheme or a KeyProvider which returns keys are configured. Otherwise it returns an error
+// which describes the problem. So it can be used in a readiness probe to detect a missing key:
+//
+//	http.HandleFunc("/readyz", func(rw http.ResponseWriter, r *http.Request) {
+//		if err := verifier.Ready(); err != nil {
+//			http.Error(rw, err.Error(), http.StatusServiceUnavailable)
+//		}
+//	})
+//
+// A KeyProvider is asked for the keys of the empty tenant id. A Verifier which derives the keys with
+// WithKeyIdDerivation but has no other keys is always ready.
+func (v *Verifier) Ready() error {
+	if v.deriveKey != nil && v.keyProvider == nil && v.scheme == nil {
+		return nil
+	}
+	scheme, vErr := v.signatureScheme(context.Background(), "")
+	if vErr != nil {
+		return vErr
+	}
+	if hmac, ok := scheme.(hmacScheme); ok {
+		for i, key := range hmac.keys {
+			if len(key) < minReadyKeyLength {
+				return fmt.Errorf("signature secret key %v has %v bytes but at least %v bytes are required", i+1, len(key), minReadyKeyLength)
+			}
+		}
+	}
+	return nil
+}
+
+// WarmKeys caches the keys of the given tenants in advance, e.g. at startup, if the KeyProvider supports it
+// like HKDFKeyProvider. So the first request of each of these tenants doesn't have to derive the key.
+// It does nothing for other KeyProviders.
+func (v *Verifier) WarmKeys(tenantIds ...string) {
+	if warmer, ok := v.keyProvider.(keyWarmer); ok {
+		warmer.WarmKeys(tenantIds...)
+	}
+}
+
+// WithSignatureSecretKeys verifies HMAC signatures with the given keys. The first key is the current key.
+// Additional keys are accepted during a key rotation.
+func WithSignatureSecretKeys(keys ...[]byte) Option {
+	return func(v *Verifier) error {
+		v.keyProvider = staticKeyProvider(keys)
+		v.scheme = nil
+		return nil
+	}
+}
+
+// WithOutboundKey sets the key which is used by Verifier.OutgoingHeaders to sign requests which are sent on behalf
+// of the tenant. It is independent of the keys which verify incoming requests, so an App may verify with the key of
+// the caller and sign with the key of the callee.
+func WithOutboundKey(key []byte) Option {
+	return func(v *Verifier) error {
+		if len(key) == 0 {
+			return errors.New("outbound key must not be empty")
+		}
+		v.outboundKey = key
+		return nil
+	}
+}
+
+// OutgoingHeaders works like the function OutgoingHeaders but signs the headers with the key configured by
+// WithOutboundKey. The keys which verify incoming requests are never used for signing.
+func (v *Verifier) OutgoingHeaders(ctx context.Context) (http.Header, error) {
+	if len(v.outboundKey) == 0 {
+		return nil, errors.New("signing tenant headers because outbound key has not been configured")
+	}
+	return OutgoingHeaders(ctx, v.outboundKey)
+}
+
+// WithLogger sets the function which is used to log why requests have been rejected.
+func WithLogger(logger func(ctx context.Context, message string)) Option {
+	return func(v *Verifier) error {
+		if logger == nil {
+			logger = func(ctx context.Context, message string) {}
+		}
+		v.logger = logger
+		return nil
+	}
+}
+
+// Chain returns a middleware which applies the tenant middleware of the verifier first and the given
+// middlewares afterwards in the given order. So the tenant information is available on the context in
+// all of the given middlewares, e.g. in a middleware which authenticates the user.
+func Chain(verifier *Verifier, next ...func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		for i := len(next) - 1; i >= 0; i-- {
+			handler = next[i](handler)
+		}
+		return verifier.Middleware(handler)
+	}
+}
@@ -0,0 +1,167 @@
+package tenant_test
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestNewVerifierWithInvalidOption_ReturnsError(t *testing.T) {
+	if _, err := tenant.NewVerifier(tenant.WithSignatureSecretKeys(signatureKey), tenant.WithMaxBaseUriLength(0)); err == nil {
+		t.Error("expected error for invalid option")
+	}
+}
+
+func TestMustNewVerifierWithInvalidOption_Panics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for invalid option")
+		}
+	}()
+
+	tenant.MustNewVerifier(tenant.WithSignatureSecretKeys(signatureKey), tenant.WithMaxBaseUriLength(0))
+}
+
+func TestVerifierMiddlewareWithValidSignature_AddsTenantToContext(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	v := tenant.MustNewVerifier(tenant.WithSignatureSecretKeys(signatureKey), tenant.WithLogger(logSpy.logError))
+	v.Middleware(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+		t.Error(err)
+	}
+	if logSpy.hasBeenCalled {
+		t.Error("logger should not have been called")
+	}
+}
+
+func TestVerifierMiddlewareWithInvalidSignature_Returns403AndLogs(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	req.Header.Set(tenantIdHeader, "other")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	logSpy := loggerSpy{}
+
+	v, err := tenant.NewVerifier(tenant.WithSignatureSecretKeys(signatureKey), tenant.WithLogger(logSpy.logError))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Middleware(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	if handlerSpy.hasBeenCalled {
+		t.Error("inner handler should not have been called")
+	}
+	if !logSpy.hasBeenCalled {
+		t.Error("logger should have been called")
+	}
+}
+
+func TestChain_TenantIsAvailableInSubsequentMiddlewaresInOrder(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com", "a12be5")
+	handlerSpy := handlerSpy{}
+	responseSpy := responseSpy{httptest.NewRecorder()}
+	var calls []string
+	middleware := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				tenantId, err := tenant.IdFromCtx(req.Context())
+				if err != nil {
+					t.Errorf("expected tenant on context in middleware %v but got '%v'", name, err)
+				}
+				calls = append(calls, name+":"+tenantId)
+				next.ServeHTTP(rw, req)
+			})
+		}
+	}
+
+	v := tenant.MustNewVerifier(tenant.WithSignatureSecretKeys(signatureKey))
+	tenant.Chain(v, middleware("auth"), middleware("audit"))(&handlerSpy).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if !handlerSpy.hasBeenCalled {
+		t.Error("inner handler should have been called")
+	}
+	if got, want := strings.Join(calls, ","), "auth:a12be5,audit:a12be5"; got != want {
+		t.Errorf("got wrong middleware calls: got %v want %v", got, want)
+	}
+}
+
+func TestKeyFingerprint_IdenticalKeysHaveIdenticalFingerprints(t *testing.T) {
+	v1 := tenant.MustNewVerifier(tenant.WithSignatureSecretKeys(signatureKey, rotatedSignatureKey))
+	v2 := tenant.MustNewVerifier(tenant.WithSignatureSecretKeys(rotatedSignatureKey, append([]byte(nil), signatureKey...)))
+
+	if v1.KeyFingerprint() == "" {
+		t.Error("expected fingerprint")
+	}
+	if v1.KeyFingerprint() != v2.KeyFingerprint() {
+		t.Errorf("expected identical fingerprints but got %v and %v", v1.KeyFingerprint(), v2.KeyFingerprint())
+	}
+	if strings.Contains(v1.KeyFingerprint(), hex.EncodeToString(signatureKey)) {
+		t.Error("fingerprint must not contain the key")
+	}
+}
+
+func TestKeyFingerprint_DifferentKeysHaveDifferentFingerprints(t *testing.T) {
+	v1 := tenant.MustNewVerifier(tenant.WithSignatureSecretKeys(signatureKey))
+	v2 := tenant.MustNewVerifier(tenant.WithSignatureSecretKeys(rotatedSignatureKey))
+
+	if v1.KeyFingerprint() == v2.KeyFingerprint() {
+		t.Errorf("expected different fingerprints but got %v for both", v1.KeyFingerprint())
+	}
+}
+
+func TestVerifierWithoutKey_Ready_ReturnsError(t *testing.T) {
+	v := tenant.MustNewVerifier()
+
+	if err := v.Ready(); err == nil {
+		t.Error("expected verifier without key not to be ready")
+	}
+}
+
+func TestVerifierWithShortKey_Ready_ReturnsError(t *testing.T) {
+	v := tenant.MustNewVerifier(tenant.WithSignatureSecretKeys(signatureKey, []byte("short")))
+
+	if err := v.Ready(); err == nil {
+		t.Error("expected verifier with short key not to be ready")
+	}
+}
+
+func TestVerifierWithKey_Ready_ReturnsNil(t *testing.T) {
+	v := tenant.MustNewVerifier(tenant.WithSignatureSecretKeys(signatureKey))
+
+	if err := v.Ready(); err != nil {
+		t.Errorf("expected verifier to be ready but got %v", err)
+	}
+}
+
+func TestVerifierWithDefaultSystemBaseUri_DefaultSystemBaseUri_ReturnsDefault(t *testing.T) {
+	v := tenant.MustNewVerifier(tenant.WithDefaultSystemBaseUri("https://default.example.com/"))
+
+	if got := v.DefaultSystemBaseUri(); got != "https://default.example.com" {
+		t.Errorf("got wrong default systemBaseUri: got %v want %v", got, "https://default.example.com")
+	}
+}
+
+func TestVerifierWithoutDefaultSystemBaseUri_DefaultSystemBaseUri_ReturnsEmptyString(t *testing.T) {
+	v := tenant.MustNewVerifier()
+
+	if got := v.DefaultSystemBaseUri(); got != "" {
+		t.Errorf("got wrong default systemBaseUri: got %v want empty string", got)
+	}
+}
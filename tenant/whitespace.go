@@ -0,0 +1,31 @@
+package tenant
+
+import (
+	"net/http"
+	"strings"
+)
+
+// WithWhitespaceCanonicalization canonicalizes the whitespace of the headers x-dv-baseuri and x-dv-tenant-id,
+// e.g. for callers which normalize the systemBaseUri before they sign it. Leading and trailing whitespace is
+// removed and each sequence of whitespace characters (as defined by unicode.IsSpace) within the value is replaced
+// by a single space, so " https://sample.example.com \t" becomes "https://sample.example.com".
+//
+// The canonical values are used to build the signed message and are stored on the context. If
+// WithCanonicalJSONSigning is used the values of all signed headers are canonicalized as well.
+// SignRequest with this option signs the canonical values.
+func WithWhitespaceCanonicalization() Option {
+	return func(v *Verifier) error {
+		v.whitespaceCanonicalization = true
+		return nil
+	}
+}
+
+// signedHeaderValue returns the value of the header which is part of the signed message. Its whitespace is
+// canonicalized according to WithWhitespaceCanonicalization.
+func (v *Verifier) signedHeaderValue(header http.Header, name string) string {
+	value := headerValue(header, name)
+	if v.whitespaceCanonicalization {
+		return strings.Join(strings.Fields(value), " ")
+	}
+	return value
+}
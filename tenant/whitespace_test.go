@@ -0,0 +1,81 @@
+package tenant_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/d-velop/dvelop-sdk-go/tenant"
+)
+
+func TestDifferentlySpacedHeaders_WhitespaceCanonicalization(t *testing.T) {
+	signature := base64Signature("https://sample.example.coma12be5", signatureKey)
+	testCases := []struct {
+		name          string
+		systemBaseUri string
+		tenantId      string
+	}{
+		{"canonical", "https://sample.example.com", "a12be5"},
+		{"leading and trailing spaces", "  https://sample.example.com ", " a12be5"},
+		{"tabs", "\thttps://sample.example.com\t", "a12be5\t"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := signedRequest(t, tc.systemBaseUri, tc.tenantId)
+			req.Header.Set(signatureHeader, signature)
+			handlerSpy := handlerSpy{}
+			responseSpy := responseSpy{httptest.NewRecorder()}
+
+			tenant.AddToCtx("", signatureKey, nil, tenant.WithWhitespaceCanonicalization())(&handlerSpy).ServeHTTP(responseSpy, req)
+
+			if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+				t.Error(err)
+			}
+			if err := handlerSpy.assertTenantIdIs("a12be5"); err != nil {
+				t.Error(err)
+			}
+			if err := handlerSpy.assertBaseUriIs("https://sample.example.com"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestInternalWhitespace_WhitespaceCanonicalization_CollapsesWhitespace(t *testing.T) {
+	signature := base64Signature("https://sample.example.com/my apptenant a", signatureKey)
+	for _, systemBaseUri := range []string{"https://sample.example.com/my app", "https://sample.example.com/my  \t app"} {
+		req := signedRequest(t, systemBaseUri, "tenant \n a")
+		req.Header.Set(signatureHeader, signature)
+		responseSpy := responseSpy{httptest.NewRecorder()}
+
+		tenant.AddToCtx("", signatureKey, nil, tenant.WithWhitespaceCanonicalization())(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+		if err := responseSpy.assertStatusCodeIs(http.StatusOK); err != nil {
+			t.Errorf("%q: %v", systemBaseUri, err)
+		}
+	}
+}
+
+func TestDifferentlySpacedHeaders_NoWhitespaceCanonicalization_Returns403(t *testing.T) {
+	req := signedRequest(t, " https://sample.example.com", "a12be5")
+	req.Header.Set(signatureHeader, base64Signature("https://sample.example.coma12be5", signatureKey))
+	responseSpy := responseSpy{httptest.NewRecorder()}
+
+	tenant.AddToCtx("", signatureKey, nil)(&handlerSpy{}).ServeHTTP(responseSpy, req)
+
+	if err := responseSpy.assertStatusCodeIs(http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSignRequest_WhitespaceCanonicalization_SignsCanonicalValues(t *testing.T) {
+	req := signedRequest(t, "https://sample.example.com  ", "  a12be5")
+
+	if err := tenant.SignRequest(req, signatureKey, tenant.WithWhitespaceCanonicalization()); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := base64Signature("https://sample.example.coma12be5", signatureKey); req.Header.Get(signatureHeader) != want {
+		t.Errorf("got wrong signature: got %v want %v", req.Header.Get(signatureHeader), want)
+	}
+}